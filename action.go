@@ -0,0 +1,392 @@
+package snappr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/shellwords"
+)
+
+// Snapshot is a snapshot to be acted on.
+type Snapshot struct {
+	Name    string    // e.g., the input line or file name
+	Time    time.Time // the time of the snapshot
+	Reasons []Period  // the periods requiring the snapshot, if any
+}
+
+// Action does something with a set of snapshots (usually the ones being
+// pruned).
+type Action interface {
+	Apply(ctx context.Context, snapshots []Snapshot) error
+}
+
+// ActionFunc adapts a function into an Action.
+type ActionFunc func(ctx context.Context, snapshots []Snapshot) error
+
+// Apply calls fn.
+func (fn ActionFunc) Apply(ctx context.Context, snapshots []Snapshot) error {
+	return fn(ctx, snapshots)
+}
+
+var actions = struct {
+	sync.Mutex
+	m map[string]func(arg string) (Action, error)
+}{m: map[string]func(string) (Action, error){
+	"print": func(arg string) (Action, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("print does not take an argument")
+		}
+		return PrintAction{W: os.Stdout}, nil
+	},
+	"exec": func(arg string) (Action, error) {
+		args, err := shellwords.Split(arg)
+		if err != nil {
+			return nil, fmt.Errorf("exec command is invalid: %w", err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("exec requires a command")
+		}
+		return ExecAction{Command: args}, nil
+	},
+	"delete-file": func(arg string) (Action, error) {
+		return DeleteFileAction{Dir: arg}, nil
+	},
+	"trash": func(arg string) (Action, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("trash requires a directory")
+		}
+		return TrashAction{Dir: arg}, nil
+	},
+	"rename": func(arg string) (Action, error) {
+		if arg == "" {
+			arg = ".pruned"
+		}
+		return RenameAction{Suffix: arg}, nil
+	},
+	"webhook": func(arg string) (Action, error) {
+		if !strings.HasPrefix(arg, "http://") && !strings.HasPrefix(arg, "https://") {
+			return nil, fmt.Errorf("webhook requires an http(s) url")
+		}
+		return WebhookAction{URL: arg}, nil
+	},
+}}
+
+// RegisterAction registers a named action for use with ParseAction. The
+// function is called with the argument after the colon, if any. It panics if
+// the name is already registered.
+func RegisterAction(name string, fn func(arg string) (Action, error)) {
+	actions.Lock()
+	defer actions.Unlock()
+	if _, ok := actions.m[name]; ok {
+		panic("snappr: action " + name + " already registered")
+	}
+	actions.m[name] = fn
+}
+
+// Actions returns the names of the registered actions.
+func Actions() []string {
+	actions.Lock()
+	defer actions.Unlock()
+	names := make([]string, 0, len(actions.m))
+	for name := range actions.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseAction creates a registered action from a spec in the form name or
+// name:arg. The built-in actions are print, exec:command (the names are
+// appended to the command, which is split into arguments like a shell would,
+// so arguments can be quoted), delete-file[:dir] (relative
+// names are resolved against dir), trash:dir (see TrashAction),
+// rename[:suffix] (the suffix defaults to .pruned), and webhook:url (the
+// snapshots are POSTed as JSON).
+func ParseAction(spec string) (Action, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	actions.Lock()
+	fn, ok := actions.m[name]
+	actions.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown action %q", name)
+	}
+	a, err := fn(arg)
+	if err != nil {
+		return nil, fmt.Errorf("action %q: %w", name, err)
+	}
+	return a, nil
+}
+
+// PrintAction writes the name of each snapshot on a separate line.
+type PrintAction struct {
+	W io.Writer
+}
+
+// Apply implements Action.
+func (a PrintAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	for _, s := range snapshots {
+		if _, err := fmt.Fprintln(a.W, s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecAction runs a command with the snapshot names appended to the
+// arguments.
+type ExecAction struct {
+	Command []string
+	Stdout  io.Writer // if nil, output is discarded
+	Stderr  io.Writer // if nil, output is included in the error
+}
+
+// Apply implements Action.
+func (a ExecAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	args := slices.Clip(a.Command[1:])
+	for _, s := range snapshots {
+		args = append(args, s.Name)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, a.Command[0], args...)
+	cmd.Stdout = a.Stdout
+	cmd.Stderr = a.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	}
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() != 0 {
+			return fmt.Errorf("exec %s: %w (stderr: %q)", a.Command[0], err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return fmt.Errorf("exec %s: %w", a.Command[0], err)
+	}
+	return nil
+}
+
+// DeleteFileAction deletes each snapshot's name as a file. Files which do not
+// exist are ignored.
+type DeleteFileAction struct {
+	Dir string // if set, relative names are resolved against it
+}
+
+// Apply implements Action.
+func (a DeleteFileAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	var errs []error
+	for _, s := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := s.Name
+		if a.Dir != "" && !filepath.IsAbs(name) {
+			name = filepath.Join(a.Dir, name)
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TrashAction moves each snapshot's name as a file (or directory) into a trash
+// directory, prefixing it with the unix time it was moved at and a dot (see
+// TrashTime). The trash directory must be on the same filesystem.
+type TrashAction struct {
+	Dir string
+	Now func() time.Time // if nil, time.Now is used
+}
+
+// Apply implements Action.
+func (a TrashAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	if err := os.MkdirAll(a.Dir, 0777); err != nil {
+		return err
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	var errs []error
+	for _, s := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		base := strconv.FormatInt(now().Unix(), 10) + "." + filepath.Base(s.Name)
+		dst := filepath.Join(a.Dir, base)
+		for i := 1; ; i++ {
+			if _, err := os.Lstat(dst); errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			dst = filepath.Join(a.Dir, base+"."+strconv.Itoa(i))
+		}
+		if err := os.Rename(s.Name, dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TrashTime gets the time a file was moved to the trash by TrashAction from
+// its name.
+func TrashTime(name string) (time.Time, bool) {
+	ts, _, ok := strings.Cut(filepath.Base(name), ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0), true
+}
+
+// RenameAction renames each snapshot's name as a file (or directory) by
+// appending a suffix.
+type RenameAction struct {
+	Suffix string
+}
+
+// Apply implements Action.
+func (a RenameAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	var errs []error
+	for _, s := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := os.Rename(s.Name, s.Name+a.Suffix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookAction POSTs the snapshots as a JSON array of objects with the name,
+// time, and reasons.
+type WebhookAction struct {
+	URL    string
+	Client *http.Client // if nil, http.DefaultClient is used
+}
+
+// Apply implements Action.
+func (a WebhookAction) Apply(ctx context.Context, snapshots []Snapshot) error {
+	type snapshot struct {
+		Name    string    `json:"name"`
+		Time    time.Time `json:"time"`
+		Reasons []string  `json:"reasons"`
+	}
+	body := make([]snapshot, len(snapshots))
+	for i, s := range snapshots {
+		body[i] = snapshot{Name: s.Name, Time: s.Time, Reasons: []string{}}
+		for _, p := range s.Reasons {
+			body[i].Reasons = append(body[i].Reasons, p.String())
+		}
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: response status %s", resp.Status)
+	}
+	return nil
+}
+
+// ApplyEach applies an action to each snapshot separately so failures only
+// affect the snapshots they occurred for, returning the snapshots which failed
+// and the joined errors. If continueOnError is false, it stops at the first
+// failure, and the remaining snapshots are also returned as failed.
+func ApplyEach(ctx context.Context, a Action, snapshots []Snapshot, continueOnError bool) (failed []Snapshot, err error) {
+	var errs []error
+	for i, s := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return append(failed, snapshots[i:]...), errors.Join(append(errs, err)...)
+		}
+		if err := a.Apply(ctx, snapshots[i:i+1]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			if !continueOnError {
+				return append(failed, snapshots[i:]...), errors.Join(errs...)
+			}
+			failed = append(failed, s)
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+// WithRetry wraps an action to retry it up to attempts times in total, waiting
+// backoff (doubling each time, up to the maximum duration) between attempts.
+func WithRetry(a Action, attempts int, backoff time.Duration) Action {
+	return ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+		var err error
+		for i := 0; i < max(attempts, 1); i++ {
+			if i != 0 {
+				select {
+				case <-ctx.Done():
+					return errors.Join(err, ctx.Err())
+				case <-time.After(retryDelay(backoff, i)):
+				}
+			}
+			if err = a.Apply(ctx, snapshots); err == nil {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// retryDelay returns backoff doubled for each retry after the first, without
+// overflowing.
+func retryDelay(backoff time.Duration, retry int) time.Duration {
+	shift := min(max(retry-1, 0), 62)
+	if backoff > math.MaxInt64>>shift {
+		return math.MaxInt64
+	}
+	return backoff << shift
+}
+
+// WithBatches wraps an action to apply it to at most size snapshots at a time,
+// waiting interval between batches to limit the rate.
+func WithBatches(a Action, size int, interval time.Duration) Action {
+	return ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+		for i := 0; i < len(snapshots); i += max(size, 1) {
+			if i != 0 && interval > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(interval):
+				}
+			}
+			if err := a.Apply(ctx, snapshots[i:min(i+max(size, 1), len(snapshots))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
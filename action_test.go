@@ -0,0 +1,226 @@
+package snappr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestActions(t *testing.T) {
+	snapshots := []Snapshot{
+		{Name: "a", Time: time.Unix(1, 0).UTC()},
+		{Name: "b", Time: time.Unix(2, 0).UTC(), Reasons: []Period{{Unit: Daily, Interval: 1}}},
+	}
+
+	t.Run("Print", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := (PrintAction{W: &buf}).Apply(context.Background(), snapshots); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if act, exp := buf.String(), "a\nb\n"; act != exp {
+			t.Errorf("expected %q, got %q", exp, act)
+		}
+	})
+
+	t.Run("DeleteFile", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a"), nil, 0666); err != nil {
+			panic(err)
+		}
+		a, err := ParseAction("delete-file:" + dir)
+		if err != nil {
+			t.Fatalf("parse: unexpected error: %v", err)
+		}
+		if err := a.Apply(context.Background(), snapshots); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "a")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected file to be deleted")
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "a")
+		if err := os.WriteFile(name, nil, 0666); err != nil {
+			panic(err)
+		}
+		a, err := ParseAction("rename")
+		if err != nil {
+			t.Fatalf("parse: unexpected error: %v", err)
+		}
+		if err := a.Apply(context.Background(), []Snapshot{{Name: name}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(name + ".pruned"); err != nil {
+			t.Errorf("expected file to be renamed: %v", err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		dir := t.TempDir()
+		trash := filepath.Join(dir, "trash")
+		for _, name := range []string{"a", "b"} {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+				panic(err)
+			}
+		}
+		a := TrashAction{Dir: trash, Now: func() time.Time { return time.Unix(1000, 0) }}
+		if err := a.Apply(context.Background(), []Snapshot{{Name: filepath.Join(dir, "a")}, {Name: filepath.Join(dir, "b")}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, name := range []string{"1000.a", "1000.b"} {
+			if tt, ok := TrashTime(name); !ok || tt.Unix() != 1000 {
+				t.Errorf("incorrect trash time for %q", name)
+			}
+			if _, err := os.Stat(filepath.Join(trash, name)); err != nil {
+				t.Errorf("expected file to be in the trash: %v", err)
+			}
+		}
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		var body []map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid body: %v", err)
+			}
+		}))
+		defer srv.Close()
+
+		a, err := ParseAction("webhook:" + srv.URL)
+		if err != nil {
+			t.Fatalf("parse: unexpected error: %v", err)
+		}
+		if err := a.Apply(context.Background(), snapshots); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(body) != 2 || body[1]["name"] != "b" || body[1]["time"] != "1970-01-01T00:00:02Z" || len(body[1]["reasons"].([]any)) != 1 {
+			t.Errorf("incorrect body: %v", body)
+		}
+	})
+
+	t.Run("ParseExec", func(t *testing.T) {
+		a, err := ParseAction(`exec:rm -f "/mnt/my backups" 'a b'\ c`)
+		if err != nil {
+			t.Fatalf("parse: unexpected error: %v", err)
+		}
+		if act, exp := a.(ExecAction).Command, []string{"rm", "-f", "/mnt/my backups", "a b c"}; !slices.Equal(act, exp) {
+			t.Errorf("expected command %q, got %q", exp, act)
+		}
+		if _, err := ParseAction(`exec:rm "unterminated`); err == nil {
+			t.Errorf("expected error for unterminated quote")
+		}
+		if _, err := ParseAction("exec: "); err == nil {
+			t.Errorf("expected error for empty command")
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		var got []string
+		RegisterAction("test", func(arg string) (Action, error) {
+			return ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+				for _, s := range snapshots {
+					got = append(got, arg+s.Name)
+				}
+				return nil
+			}), nil
+		})
+		if !slices.Contains(Actions(), "test") {
+			t.Errorf("expected registered action to be listed")
+		}
+		a, err := ParseAction("test:x")
+		if err != nil {
+			t.Fatalf("parse: unexpected error: %v", err)
+		}
+		if err := a.Apply(context.Background(), snapshots); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(got, []string{"xa", "xb"}) {
+			t.Errorf("incorrect result: %q", got)
+		}
+		if _, err := ParseAction("nonexistent"); err == nil {
+			t.Errorf("expected error for unknown action")
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		var n int
+		a := WithRetry(ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+			if n++; n < 3 {
+				return errors.New("fail")
+			}
+			return nil
+		}), 3, time.Millisecond)
+		if err := a.Apply(context.Background(), snapshots); err != nil || n != 3 {
+			t.Errorf("expected success after 3 attempts, got %d attempts (error: %v)", n, err)
+		}
+	})
+
+	t.Run("RetryDelay", func(t *testing.T) {
+		for _, tc := range []struct {
+			retry int
+			exp   time.Duration
+		}{
+			{1, time.Second},
+			{2, 2 * time.Second},
+			{4, 8 * time.Second},
+			{40, math.MaxInt64},
+			{100, math.MaxInt64},
+		} {
+			if act := retryDelay(time.Second, tc.retry); act != tc.exp {
+				t.Errorf("retry %d: expected delay %s, got %s", tc.retry, tc.exp, act)
+			}
+		}
+	})
+
+	t.Run("ApplyEach", func(t *testing.T) {
+		fail := ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+			if snapshots[0].Name == "a" {
+				return errors.New("fail")
+			}
+			return nil
+		})
+		for _, tc := range []struct {
+			continueOnError bool
+			failed          []string
+		}{
+			{false, []string{"a", "b"}},
+			{true, []string{"a"}},
+		} {
+			failed, err := ApplyEach(context.Background(), fail, snapshots, tc.continueOnError)
+			if err == nil {
+				t.Errorf("continueOnError=%t: expected error", tc.continueOnError)
+			}
+			var names []string
+			for _, s := range failed {
+				names = append(names, s.Name)
+			}
+			if !slices.Equal(names, tc.failed) {
+				t.Errorf("continueOnError=%t: expected %q to fail, got %q", tc.continueOnError, tc.failed, names)
+			}
+		}
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		var sizes []int
+		a := WithBatches(ActionFunc(func(ctx context.Context, snapshots []Snapshot) error {
+			sizes = append(sizes, len(snapshots))
+			return nil
+		}), 2, 0)
+		if err := a.Apply(context.Background(), append(snapshots, snapshots...)[:3]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(sizes, []int{2, 1}) {
+			t.Errorf("incorrect batch sizes: %v", sizes)
+		}
+	})
+}
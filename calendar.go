@@ -0,0 +1,372 @@
+package snappr
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calendar splits time into intervals for periods with units other than Last,
+// Within, Workdaily, and Cron (e.g., to use fiscal, 4-4-5 retail, or
+// non-Gregorian calendars). Custom calendars can embed Gregorian to handle the
+// units they don't change.
+type Calendar interface {
+	// BucketKey returns the index of the interval of the period containing t,
+	// taking the interval and offset of the period into account. The time is
+	// already in the location of the period, and shifted later by its slack.
+	// The index must never decrease as t increases.
+	BucketKey(t time.Time, p Period) int64
+}
+
+// Gregorian is the default Calendar, which splits intervals at the start of
+// calendar days, weeks, months, quarters, and years in the proleptic Gregorian
+// calendar.
+type Gregorian struct {
+	// MonthMode controls how monthly periods are split.
+	MonthMode MonthMode
+
+	// WeekMode controls the day weekly periods start on.
+	WeekMode WeekMode
+
+	// FiscalYearStart is the first month of the first quarter for quarterly
+	// periods. If zero, it is January.
+	FiscalYearStart time.Month
+}
+
+// BucketKey implements Calendar.
+func (g Gregorian) BucketKey(t time.Time, p Period) int64 {
+	var current int64
+	switch p.Unit {
+	case Millisecondly:
+		current = t.UnixMilli()
+	case Secondly:
+		current = t.Unix()
+	case Minutely:
+		current = floorDiv(t.Unix(), 60)
+	case Ordinal:
+		current = t.Unix()
+	case Daily:
+		if p.legacyDaily() {
+			return legacyDailyKey(t, p)
+		}
+		current = epochDay(t)
+		if p.Anchor != "" {
+			current -= anchorWeekday(p.Anchor)
+		}
+	case Weekly:
+		first := g.WeekMode.firstDay()
+		if p.Anchor != "" {
+			first = anchorWeekday(p.Anchor)
+		}
+		current = floorDiv(epochDay(t)-first, 7)
+	case Monthly:
+		if g.MonthMode == FixedMonth {
+			current = floorDiv(epochDay(t), 30)
+			break
+		}
+		current = anchorMonth(t, 0, p.Anchor) + 1 // from December of year -1
+	case Quarterly:
+		current = floorDiv(anchorMonth(t, g.quarterMonth(p), p.Anchor), 3)
+	case Yearly:
+		if p.Anchor == "" {
+			current = int64(t.Year())
+			break
+		}
+		current = floorDiv(anchorMonth(t, 0, p.Anchor), 12)
+	default:
+		panic("wtf")
+	}
+	return floorDiv(current-int64(p.Offset), int64(p.Interval))
+}
+
+// bucketStart returns the start of the interval with index i in loc, without
+// taking the slack into account. It is the inverse of BucketKey.
+func (g Gregorian) bucketStart(i int64, p Period, loc *time.Location) time.Time {
+	var t time.Time
+	n := i*int64(p.Interval) + int64(p.Offset)
+	switch p.Unit {
+	case Millisecondly:
+		t = time.UnixMilli(n).In(loc)
+	case Secondly:
+		t = time.Unix(n, 0).In(loc)
+	case Minutely:
+		t = time.Unix(n*60, 0).In(loc)
+	case Ordinal:
+		t = time.Unix(n, 0).In(loc)
+	case Daily:
+		if p.legacyDaily() {
+			t = legacyDailyStart(i, p, loc)
+			break
+		}
+		if p.Anchor != "" {
+			n += anchorWeekday(p.Anchor)
+		}
+		t = startOfDay(1970, 1, 1+int(n), loc)
+	case Weekly:
+		first := g.WeekMode.firstDay()
+		if p.Anchor != "" {
+			first = anchorWeekday(p.Anchor)
+		}
+		t = startOfDay(1970, 1, 1+int(n*7+first), loc)
+	case Monthly:
+		if g.MonthMode == FixedMonth {
+			t = startOfDay(1970, 1, 1+int(n)*30, loc)
+			break
+		}
+		t = anchorMonthStart(n-1, 0, p.Anchor, loc)
+	case Quarterly:
+		t = anchorMonthStart(n*3, g.quarterMonth(p), p.Anchor, loc)
+	case Yearly:
+		t = anchorMonthStart(n*12, 0, p.Anchor, loc)
+	default:
+		panic("wtf")
+	}
+	return t
+}
+
+// quarterMonth returns the number of months between January and the fiscal
+// year start for a quarterly period without an anchor.
+func (g Gregorian) quarterMonth(p Period) int64 {
+	if m := g.FiscalYearStart; p.Anchor != "" || m < time.January || m > time.December {
+		return 0
+	}
+	return int64(g.FiscalYearStart - time.January)
+}
+
+// legacyYears is the number of years from year 0 with the legacy alignment for
+// Daily intervals. Intervals in other years are counted from the ends.
+const legacyYears = 10000
+
+// maxLegacyDailyTables is the number of tables (of about 80 KB each) cached by
+// legacyDailyTable before the cache is cleared.
+const maxLegacyDailyTables = 16
+
+// legacyDailyTables caches legacyDailyTable by interval and offset.
+var legacyDailyTables struct {
+	sync.Mutex
+	m map[[2]int][]int64
+}
+
+// legacyDaily checks whether the period is a multi-day Daily one with the
+// legacy alignment.
+func (p Period) legacyDaily() bool {
+	return p.Unit == Daily && p.Interval != 1 && p.Anchor == ""
+}
+
+// legacyDay returns the number the original version of snappr used to align
+// Daily intervals for a day of the year. It is the day of the year plus a
+// number which only increases every 4 years, so the alignment of multi-day
+// intervals restarts at the start of each year.
+func legacyDay(year, yday int) int64 {
+	n := int64(year)
+	a := n / 400
+	n -= a * 400
+	b := n / 100
+	n -= b * 100
+	c := n / 4
+	return a*(365*400+97) + b*(365*100+24) + c*(365*4+1) + c + int64(yday)
+}
+
+// legacyDailyGroup returns the legacy index of the Daily interval containing a
+// day of the year, which may decrease at the start of a year.
+func (p Period) legacyDailyGroup(year, yday int) int64 {
+	return floorDiv(legacyDay(year, yday)-int64(p.Offset), int64(p.Interval))
+}
+
+// legacyDailyTable returns the key of the interval containing January 1 of
+// each year from 0 to legacyYears for a Daily period with the legacy
+// alignment. The keys increase by one whenever the legacy index changes.
+func legacyDailyTable(p Period) []int64 {
+	k := [2]int{p.Interval, p.Offset}
+	legacyDailyTables.Lock()
+	first, ok := legacyDailyTables.m[k]
+	legacyDailyTables.Unlock()
+	if ok {
+		return first
+	}
+	first = make([]int64, legacyYears+1)
+	for y := 0; y < legacyYears; y++ {
+		a, b := p.legacyDailyGroup(y, 1), p.legacyDailyGroup(y, daysIn(y))
+		if first[y+1] = first[y] + b - a; p.legacyDailyGroup(y+1, 1) != b {
+			first[y+1]++
+		}
+	}
+	legacyDailyTables.Lock()
+	if legacyDailyTables.m == nil || len(legacyDailyTables.m) >= maxLegacyDailyTables {
+		legacyDailyTables.m = map[[2]int][]int64{}
+	}
+	legacyDailyTables.m[k] = first
+	legacyDailyTables.Unlock()
+	return first
+}
+
+// legacyDailyKey returns the key of the interval containing t for a Daily
+// period with the legacy alignment.
+func legacyDailyKey(t time.Time, p Period) int64 {
+	first, n := legacyDailyTable(p), int64(p.Interval)
+	switch year := t.Year(); {
+	case year < 0:
+		start := epochDay(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))
+		return floorDiv(epochDay(t)-start, n)
+	case year >= legacyYears:
+		start := epochDay(time.Date(legacyYears, 1, 1, 0, 0, 0, 0, time.UTC))
+		return first[legacyYears] + floorDiv(epochDay(t)-start, n)
+	default:
+		g := p.legacyDailyGroup(year, t.YearDay()) - p.legacyDailyGroup(year, 1)
+		return first[year] + g
+	}
+}
+
+// legacyDailyStart is the inverse of legacyDailyKey.
+func legacyDailyStart(i int64, p Period, loc *time.Location) time.Time {
+	first, n := legacyDailyTable(p), int64(p.Interval)
+	switch {
+	case i < 0:
+		return startOfDay(0, 1, 1+int(i*n), loc)
+	case i >= first[legacyYears]:
+		return startOfDay(legacyYears, 1, 1+int((i-first[legacyYears])*n), loc)
+	}
+	year := sort.Search(legacyYears+1, func(y int) bool {
+		return first[y] > i
+	}) - 1
+	for year > 0 && first[year] == i &&
+		p.legacyDailyGroup(year, 1) == p.legacyDailyGroup(year-1, daysIn(year-1)) {
+		year-- // started in the previous year
+	}
+	v := p.legacyDailyGroup(year, 1) + i - first[year]
+	day := v*n + int64(p.Offset) - legacyDay(year, 0)
+	return startOfDay(year, 1, int(max(1, day)), loc)
+}
+
+// daysIn returns the number of days in the year.
+func daysIn(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// Workdays determines the working days for periods with the Workdaily unit.
+type Workdays interface {
+	// IsWorkday checks whether the date of t (midnight UTC) is a working day.
+	IsWorkday(t time.Time) bool
+}
+
+// WorkdaysFunc adapts a function to a Workdays.
+type WorkdaysFunc func(t time.Time) bool
+
+// IsWorkday implements Workdays.
+func (f WorkdaysFunc) IsWorkday(t time.Time) bool {
+	return f(t)
+}
+
+// Weekdays is the default Workdays, where Monday through Friday are working
+// days.
+type Weekdays struct{}
+
+// IsWorkday implements Workdays.
+func (Weekdays) IsWorkday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// maxHolidays is the maximum number of consecutive days which are not working
+// days before a day is considered a working day anyways.
+const maxHolidays = 366
+
+// workday returns the epoch day of the last working day at or before the epoch
+// day d, or the first one after it.
+func (o *PruneOptions) workday(d int64, after bool) int64 {
+	w := o.Workdays
+	if w == nil {
+		w = Weekdays{}
+	}
+	if after {
+		d++
+	}
+	for i := int64(0); i < maxHolidays; i++ {
+		x := d - i
+		if after {
+			x = d + i
+		}
+		if w.IsWorkday(time.Unix(x*24*60*60, 0).UTC()) {
+			return x
+		}
+	}
+	return d
+}
+
+// parseAnchor validates and canonicalizes an anchor for the unit (see
+// Period.Anchor).
+func parseAnchor(unit Unit, s string) (string, bool) {
+	if s == "" {
+		return "", true
+	}
+	switch unit {
+	case Daily, Weekly:
+		s = strings.ToLower(s)
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if s == strings.ToLower(d.String()) {
+				return s, true
+			}
+		}
+	case Monthly:
+		if d, err := strconv.Atoi(s); err == nil && d >= 1 && d <= 28 {
+			return strconv.Itoa(d), true
+		}
+	case Quarterly, Yearly:
+		if m, d, ok := strings.Cut(s, "-"); ok {
+			vm, err1 := strconv.Atoi(m)
+			vd, err2 := strconv.Atoi(d)
+			if err1 == nil && err2 == nil && vm >= 1 && vm <= 12 && vd >= 1 && vd <= 28 {
+				return time.Date(2000, time.Month(vm), vd, 0, 0, 0, 0, time.UTC).Format("01-02"), true
+			}
+		}
+	}
+	return "", false
+}
+
+// anchorWeekday returns the epoch day of the start of the week containing
+// 1970-01-01 (a Thursday) for a weekday anchor.
+func anchorWeekday(anchor string) int64 {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if anchor == strings.ToLower(d.String()) {
+			return -floorMod(int64(time.Thursday-d), 7)
+		}
+	}
+	panic("wtf")
+}
+
+// anchorDate returns the month (0-11) and day of a day of month or MM-DD
+// anchor, or January 1 if there isn't one.
+func anchorDate(anchor string) (month int64, day int) {
+	if anchor == "" {
+		return 0, 1
+	}
+	m, d, ok := strings.Cut(anchor, "-")
+	if !ok {
+		m, d = "1", m
+	}
+	vm, _ := strconv.Atoi(m)
+	vd, _ := strconv.Atoi(d)
+	return int64(vm - 1), vd
+}
+
+// anchorMonth returns the number of months between January of year 0 and the
+// start of the month containing t, where months start on the day of the anchor
+// and the year starts the specified number of months (plus the month of the
+// anchor) after January.
+func anchorMonth(t time.Time, months int64, anchor string) int64 {
+	am, ad := anchorDate(anchor)
+	year, month, day := t.Date()
+	n := int64(year)*12 + int64(month) - 1 - months - am
+	if day < ad {
+		n--
+	}
+	return n
+}
+
+// anchorMonthStart is the inverse of anchorMonth.
+func anchorMonthStart(n int64, months int64, anchor string, loc *time.Location) time.Time {
+	am, ad := anchorDate(anchor)
+	m := n + months + am
+	return startOfDay(int(floorDiv(m, 12)), time.Month(floorMod(m, 12)+1), ad, loc)
+}
@@ -0,0 +1,185 @@
+package snappr
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// retailCalendar splits months into 4-4-5 week periods, with quarters of 13
+// weeks starting on the Monday before 1970-01-01.
+type retailCalendar struct {
+	Gregorian
+}
+
+func (c retailCalendar) BucketKey(t time.Time, p Period) int64 {
+	if p.Unit != Monthly {
+		return c.Gregorian.BucketKey(t, p)
+	}
+	w := floorDiv(epochDay(t)-MondayWeek.firstDay(), 7)
+	q, r := floorDiv(w, 13), floorMod(w, 13)
+	current := q * 3
+	switch {
+	case r >= 8:
+		current += 2
+	case r >= 4:
+		current += 1
+	}
+	return floorDiv(current-int64(p.Offset), int64(p.Interval))
+}
+
+func TestCalendar(t *testing.T) {
+	policy, err := ParsePolicy("2@daily", "monthly")
+	if err != nil {
+		panic(err)
+	}
+
+	// 2024-01-22 is the first day of a retail quarter
+	var snapshots []time.Time
+	for d := 0; d < 91; d++ {
+		snapshots = append(snapshots, time.Date(2024, 1, 22+d, 12, 0, 0, 0, time.UTC))
+	}
+
+	for mode, exp := range map[SelectMode][]int{
+		FirstSnapshot:   {0, 28, 56, 89, 90},
+		ClosestSnapshot: {0, 27, 55, 89, 90}, // 12h before the start rather than 12h after
+	} {
+		act := PruneResult(snapshots, policy, time.UTC, &PruneOptions{
+			Calendar:   retailCalendar{},
+			SelectMode: mode,
+		}).Kept()
+		if !slices.Equal(act, exp) {
+			t.Errorf("mode %d: expected kept %v, got %v", mode, exp, act)
+		}
+	}
+
+	period := Period{Unit: Monthly, Interval: 1}
+	opt := PruneOptions{Calendar: retailCalendar{}}
+	for _, s := range snapshots {
+		i := period.bucket(s, time.UTC, &opt)
+		if a, b := period.bucketStart(i, time.UTC, &opt), period.bucketStart(i+1, time.UTC, &opt); s.Before(a) || !s.Before(b) {
+			t.Errorf("%s: not in interval %d [%s, %s)", s, i, a, b)
+		}
+	}
+
+	// explicitly using the default calendar should be identical
+	for _, opt := range []PruneOptions{
+		{WeekMode: SundayWeek, FiscalYearStart: time.April},
+		{MonthMode: FixedMonth},
+	} {
+		policy, err := ParsePolicy("3@daily", "4@weekly", "monthly", "quarterly")
+		if err != nil {
+			panic(err)
+		}
+		exp := PruneResult(snapshots, policy, time.UTC, &opt)
+		opt.Calendar = opt.gregorian()
+		if act := PruneResult(snapshots, policy, time.UTC, &opt); act.Hash() != exp.Hash() {
+			t.Errorf("expected the same result with an explicit Gregorian calendar")
+		}
+	}
+}
+
+func TestPeriodAnchor(t *testing.T) {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) // a Saturday
+	for rule, exp := range map[string]time.Time{
+		"yearly@anchor=04-01":      time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		"yearly@anchor=06-02":      time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC),
+		"quarterly@anchor=02-10":   time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC),
+		"monthly@anchor=15":        time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+		"weekly@anchor=sunday":     time.Date(2024, 5, 26, 0, 0, 0, 0, time.UTC),
+		"daily:7@anchor=wednesday": time.Date(2024, 5, 29, 0, 0, 0, 0, time.UTC),
+		"daily:7@anchor=saturday":  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		period, err := ParsePeriod(rule)
+		if err != nil {
+			panic(err)
+		}
+		for _, opt := range []PruneOptions{{}, {WeekMode: SundayWeek, FiscalYearStart: time.March}} {
+			if act := period.bucketStart(period.bucket(at, time.UTC, &opt), time.UTC, &opt); !act.Equal(exp) {
+				t.Errorf("%s: expected interval containing %s to start at %s, got %s", rule, at, exp, act)
+			}
+		}
+	}
+}
+
+func TestLegacyDaily(t *testing.T) {
+	// multi-day intervals keep the alignment of the original version, where
+	// the day number restarts at the start of each year
+	for _, rule := range []string{"daily:2", "daily:7", "daily:7+3", "daily:30+29", "daily:400", "daily:1000+999"} {
+		period, err := ParsePeriod(rule)
+		if err != nil {
+			panic(err)
+		}
+		var last, lastGroup int64
+		for d := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() < 2006; d = d.AddDate(0, 0, 1) {
+			i, group := period.bucket(d, time.UTC, new(PruneOptions)), period.legacyDailyGroup(d.Year(), d.YearDay())
+			if d.Year() != 1999 || d.YearDay() != 1 {
+				if (i != last) != (group != lastGroup) || (i != last && i != last+1) {
+					t.Errorf("%s: %s: interval changed from %d to %d, but legacy interval changed from %d to %d", rule, d.Format("2006-01-02"), last, i, lastGroup, group)
+				}
+			}
+			if i != last || d.Year() == 1999 && d.YearDay() == 1 {
+				if act := period.bucketStart(i, time.UTC, new(PruneOptions)); !act.Equal(d) && (d.Year() != 1999 || d.YearDay() != 1 || act.After(d)) {
+					t.Errorf("%s: expected interval %d to start at %s, got %s", rule, i, d.Format("2006-01-02"), act.Format("2006-01-02"))
+				}
+			}
+			last, lastGroup = i, group
+		}
+	}
+
+	// the tables are only cached for a limited number of periods
+	for i := 2; i < 2+maxLegacyDailyTables*2; i++ {
+		legacyDailyTable(Period{Unit: Daily, Interval: i})
+	}
+	legacyDailyTables.Lock()
+	defer legacyDailyTables.Unlock()
+	if n := len(legacyDailyTables.m); n > maxLegacyDailyTables {
+		t.Errorf("expected at most %d cached tables, got %d", maxLegacyDailyTables, n)
+	}
+}
+
+func TestWorkdaily(t *testing.T) {
+	policy, err := ParsePolicy("4@workdaily")
+	if err != nil {
+		panic(err)
+	}
+
+	// daily from Thursday 2024-12-19 to Friday 2024-12-27
+	var snapshots []time.Time
+	for d := 0; d < 9; d++ {
+		snapshots = append(snapshots, time.Date(2024, 12, 19+d, 18, 0, 0, 0, time.UTC))
+	}
+
+	holidays := WorkdaysFunc(func(t time.Time) bool {
+		if t.Month() == time.December && (t.Day() == 25 || t.Day() == 26) {
+			return false
+		}
+		return Weekdays{}.IsWorkday(t)
+	})
+	for _, tc := range []struct {
+		workdays Workdays
+		mode     SelectMode
+		exp      []int
+	}{
+		{nil, FirstSnapshot, []int{5, 6, 7, 8}},      // Tue, Wed, Thu, Fri
+		{holidays, FirstSnapshot, []int{1, 4, 5, 8}}, // Fri, Mon, Tue, Fri
+		{holidays, LastSnapshot, []int{3, 4, 7, 8}},  // Sun, Mon, Thu, Fri
+	} {
+		act := PruneResult(snapshots, policy, time.UTC, &PruneOptions{
+			Workdays:   tc.workdays,
+			SelectMode: tc.mode,
+		}).Kept()
+		if !slices.Equal(act, tc.exp) {
+			t.Errorf("holidays %t, mode %d: expected kept %v, got %v", tc.workdays != nil, tc.mode, tc.exp, act)
+		}
+	}
+
+	period := Period{Unit: Workdaily, Interval: 1}
+	opt := PruneOptions{Workdays: holidays}
+	for _, s := range snapshots {
+		i := period.bucket(s, time.UTC, &opt)
+		if a, b := period.bucketStart(i, time.UTC, &opt), period.bucketStart(period.nextBucket(i, &opt), time.UTC, &opt); s.Before(a) || !s.Before(b) || !holidays(a) {
+			t.Errorf("%s: not in working day interval %d [%s, %s)", s, i, a, b)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// bundle contains everything about a single run for --bundle.
+type bundle struct {
+	HashInput bool     // store the hash of each input line rather than the line itself
+	Args      []string // command-line arguments, excluding the program name
+	Input     []string // input lines
+	Policy    string   // effective policy
+	Warnings  []string // all warnings, including suppressed ones
+	Report    bundleReport
+}
+
+// bundleReport is the report.json file in a bundle.
+type bundleReport struct {
+	Run       time.Time        `json:"run"` // the reference time of the run
+	Timezone  string           `json:"timezone"`
+	Decisions []bundleDecision `json:"decisions"`
+	Missing   []bundleMissing  `json:"missing,omitempty"`
+}
+
+// bundleDecision is the decision for a single input line.
+type bundleDecision struct {
+	Index    int        `json:"index"` // input line, starting at 1
+	Group    string     `json:"group,omitempty"`
+	Time     *time.Time `json:"time,omitempty"` // nil if the line is invalid
+	Decision string     `json:"decision"`       // keep, prune, quarantine, or invalid
+	Reasons  []string   `json:"reasons,omitempty"`
+	Line     string     `json:"line,omitempty"`
+	Hash     string     `json:"hash,omitempty"` // hex sha256 of the line, instead of the line itself if hashed
+}
+
+// bundleMissing is the number of snapshots a period of a group is missing.
+type bundleMissing struct {
+	Group string `json:"group,omitempty"`
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+}
+
+// bundleVersion is the version.json file in a bundle.
+type bundleVersion struct {
+	Snappr string   `json:"snappr"`
+	Go     string   `json:"go"`
+	OS     string   `json:"os"`
+	Arch   string   `json:"arch"`
+	Args   []string `json:"args"`
+}
+
+// WriteFile atomically writes the bundle to a gzipped tar archive containing
+// input.txt (or input.sha256), policy.txt, report.json, warnings.txt, and
+// version.json.
+func (b *bundle) WriteFile(name string) error {
+	var input bytes.Buffer
+	inputName := "input.txt"
+	if b.HashInput {
+		inputName = "input.sha256"
+	}
+	for _, line := range b.Input {
+		if b.HashInput {
+			sum := sha256.Sum256([]byte(line))
+			line = hex.EncodeToString(sum[:])
+		}
+		input.WriteString(line)
+		input.WriteByte('\n')
+	}
+
+	report := b.Report
+	report.Decisions = make([]bundleDecision, len(b.Report.Decisions))
+	for i, d := range b.Report.Decisions {
+		if b.HashInput {
+			sum := sha256.Sum256([]byte(d.Line))
+			d.Line, d.Hash = "", hex.EncodeToString(sum[:])
+		}
+		report.Decisions[i] = d
+	}
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	version := bundleVersion{
+		Snappr: "(unknown)",
+		Go:     runtime.Version(),
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+		Args:   b.Args,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		version.Snappr = bi.Main.Version
+	}
+	versionJSON, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var warnings bytes.Buffer
+	for _, w := range b.Warnings {
+		warnings.WriteString(w)
+		warnings.WriteByte('\n')
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{inputName, input.Bytes()},
+		{"policy.txt", []byte(b.Policy + "\n")},
+		{"report.json", append(reportJSON, '\n')},
+		{"warnings.txt", warnings.Bytes()},
+		{"version.json", append(versionJSON, '\n')},
+	} {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  b.Report.Run,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".tmp", buf.Bytes(), 0666); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
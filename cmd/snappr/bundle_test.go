@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the contents of the files in a bundle.
+func readBundle(t *testing.T, name string) map[string]string {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read bundle: %v", err)
+		}
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read bundle: %v", err)
+		}
+		files[hdr.Name] = string(buf)
+	}
+	return files
+}
+
+func TestBundle(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		name := filepath.Join(t.TempDir(), "run.tar.gz")
+		args := []string{"snappr", "--bundle", name, "-qq", "--now", "1672704000", "1@daily", "3@yearly"}
+		if hash {
+			args = append(args, "--bundle-hash-input")
+		}
+		var stdout, stderr bytes.Buffer
+		if status := Main(args, strings.NewReader("1672531200\n1672617600\ngarbage\n1672704000\n"), &stdout, &stderr); status != 0 {
+			t.Fatalf("unexpected exit status %d: %s", status, stderr.String())
+		}
+		files := readBundle(t, name)
+
+		inputName, input := "input.txt", "1672531200\n1672617600\ngarbage\n1672704000\n"
+		if hash {
+			inputName = "input.sha256"
+		}
+		if _, ok := files[inputName]; !ok {
+			t.Errorf("hash %t: missing %s", hash, inputName)
+		} else if !hash && files[inputName] != input {
+			t.Errorf("hash %t: expected input %q, got %q", hash, input, files[inputName])
+		} else if hash && strings.Contains(files[inputName], "garbage") {
+			t.Errorf("hash %t: expected input to be hashed", hash)
+		}
+		if act, exp := files["policy.txt"], "1@daily 3@yearly\n"; act != exp {
+			t.Errorf("hash %t: expected policy %q, got %q", hash, exp, act)
+		}
+		if act := files["warnings.txt"]; !strings.Contains(act, "garbage") {
+			t.Errorf("hash %t: expected the suppressed warning, got %q", hash, act)
+		}
+		var version bundleVersion
+		if err := json.Unmarshal([]byte(files["version.json"]), &version); err != nil || version.Go == "" || len(version.Args) != len(args)-1 {
+			t.Errorf("hash %t: invalid version.json (%v): %s", hash, err, files["version.json"])
+		}
+
+		var report bundleReport
+		if err := json.Unmarshal([]byte(files["report.json"]), &report); err != nil {
+			t.Fatalf("hash %t: invalid report.json: %v", hash, err)
+		}
+		var decisions []string
+		for _, d := range report.Decisions {
+			decisions = append(decisions, d.Decision)
+			if hash != (d.Line == "" && d.Hash != "") {
+				t.Errorf("hash %t: unexpected line %q and hash %q", hash, d.Line, d.Hash)
+			}
+		}
+		if act, exp := strings.Join(decisions, " "), "keep prune invalid keep"; act != exp {
+			t.Errorf("hash %t: expected decisions %q, got %q", hash, exp, act)
+		}
+		if len(report.Missing) != 1 || report.Missing[0].Rule != "yearly" || report.Missing[0].Count != 2 {
+			t.Errorf("hash %t: expected 2 missing yearly, got %+v", hash, report.Missing)
+		}
+	}
+}
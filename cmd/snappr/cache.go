@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pgaskin/snappr"
+)
+
+// pruneResult contains the parts of a [snappr.Result] used by the command, in
+// a form which can be cached.
+type pruneResult struct {
+	Reasons [][]snappr.Period
+	Need    []pruneNeed
+	Sorted  []int
+	Hash    string // see [snappr.Result.Hash]
+}
+
+type pruneNeed struct {
+	Period snappr.Period
+	Count  int
+}
+
+// pruneCacheVersion is included in the cache key. It must be incremented
+// whenever the format of cached results or the results of pruning the same
+// input change (e.g., if intervals are split differently), so results cached
+// by older versions aren't reused.
+const pruneCacheVersion = 1
+
+// pruneCache caches prune results in a directory, keyed by a hash of the
+// inputs.
+type pruneCache struct {
+	Dir    string        // if empty, results are not cached
+	TZData fs.FS         // if not nil, the zone data is hashed rather than just the name (see snappr.SetZoneInfo)
+	TTL    time.Duration // if not zero, Clean removes results which haven't been used for this long
+}
+
+// Prune is like [snappr.PruneResult], but returns a cached result if one
+// exists for the same input.
+func (c pruneCache) Prune(snapshots []time.Time, policy snappr.Policy, loc *time.Location, opt *snappr.PruneOptions) (pruneResult, snappr.Policy) {
+	var path string
+	if c.Dir != "" {
+		if key, ok := pruneKey(snapshots, policy, loc, c.TZData, opt); ok {
+			path = filepath.Join(c.Dir, "prune-"+key)
+		}
+	}
+	if path != "" {
+		if buf, err := os.ReadFile(path); err == nil {
+			var res pruneResult
+			if err := json.Unmarshal(buf, &res); err == nil && len(res.Reasons) == len(snapshots) && res.Hash != "" {
+				var need snappr.Policy
+				for _, n := range res.Need {
+					need.Set(n.Period, n.Count)
+				}
+				now := time.Now()
+				os.Chtimes(path, now, now) // for Clean
+				if opt != nil && opt.Progress != nil {
+					var periods int
+					policy.Each(func(snappr.Period, int) {
+						periods++
+					})
+					opt.Progress(len(snapshots)*periods, len(snapshots)*periods)
+				}
+				return res, need
+			}
+		}
+	}
+
+	result := snappr.PruneResult(snapshots, policy, loc, opt)
+	res := pruneResult{
+		Reasons: result.Reasons,
+		Sorted:  result.SortedIndices(),
+		Hash:    result.Hash(),
+	}
+	result.Need.Each(func(period snappr.Period, count int) {
+		res.Need = append(res.Need, pruneNeed{period, count})
+	})
+
+	if path != "" {
+		if buf, err := json.Marshal(res); err == nil {
+			if err := os.MkdirAll(c.Dir, 0777); err == nil {
+				if err := os.WriteFile(path+".tmp", buf, 0666); err == nil {
+					os.Rename(path+".tmp", path)
+				}
+			}
+		}
+	}
+	return res, result.Need.Policy()
+}
+
+// Clean removes cached results which haven't been used within the TTL.
+func (c pruneCache) Clean() error {
+	if c.Dir == "" || c.TTL <= 0 {
+		return nil
+	}
+	ents, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, ent := range ents {
+		if !ent.Type().IsRegular() || !strings.HasPrefix(ent.Name(), "prune-") {
+			continue
+		}
+		if fi, err := ent.Info(); err == nil && time.Since(fi.ModTime()) > c.TTL {
+			if err := os.Remove(filepath.Join(c.Dir, ent.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pruneKey hashes everything affecting the result of pruning. If tzdata is not
+// nil, the contents of loc and any zones used by the policy are hashed too if
+// it contains them. If opt contains
+// options which can't be hashed (a Calendar other than Gregorian, a Clock
+// without Now, or Workdays other than holidays), ok is false.
+func pruneKey(snapshots []time.Time, policy snappr.Policy, loc *time.Location, tzdata fs.FS, opt *snappr.PruneOptions) (key string, ok bool) {
+	h := sha256.New()
+	h.Write(binary.AppendUvarint(nil, pruneCacheVersion))
+	b, _ := policy.MarshalText()
+	h.Write(b)
+	h.Write([]byte{0})
+	zones := []string{loc.String()}
+	policy.Each(func(period snappr.Period, _ int) {
+		if period.Zone != "" && !slices.Contains(zones, period.Zone) {
+			zones = append(zones, period.Zone)
+		}
+	})
+	for _, zone := range zones {
+		h.Write([]byte(zone))
+		h.Write([]byte{0})
+		if tzdata != nil && fs.ValidPath(zone) {
+			if buf, err := fs.ReadFile(tzdata, zone); err == nil {
+				h.Write(binary.AppendUvarint(nil, uint64(len(buf))))
+				h.Write(buf)
+			}
+		}
+		h.Write([]byte{0})
+	}
+	if opt == nil {
+		opt = new(snappr.PruneOptions) // so it's the same as the defaults
+	}
+	if opt.Clock != nil && opt.Now.IsZero() {
+		return "", false
+	}
+	b, _ = json.Marshal(opt) // excludes Pinned and Preferred, which are hashed below
+	h.Write(b)
+	switch c := opt.Calendar.(type) {
+	case nil:
+	case snappr.Gregorian:
+		b, _ := json.Marshal(c)
+		h.Write(b)
+	default:
+		return "", false
+	}
+	switch w := opt.Workdays.(type) {
+	case nil:
+	case holidays:
+		h.Write([]byte(w.String()))
+	default:
+		return "", false
+	}
+	h.Write([]byte{0})
+	for i, t := range snapshots {
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(t.Unix())))
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Nanosecond())))
+		var flags byte
+		if opt.Pinned != nil && opt.Pinned(i) {
+			flags |= 1
+		}
+		if opt.Preferred != nil && opt.Preferred(i) {
+			flags |= 2
+		}
+		h.Write([]byte{flags})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
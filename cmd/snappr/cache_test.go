@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pgaskin/snappr"
+)
+
+func TestPruneCache(t *testing.T) {
+	var snapshots []time.Time
+	for i := 0; i < 100; i++ {
+		snapshots = append(snapshots, time.Unix(1672531200+int64(i)*3600*7, 0))
+	}
+	policy, err := snappr.ParsePolicy("1@last", "7@daily", "3@monthly", "1@yearly")
+	if err != nil {
+		panic(err)
+	}
+
+	c := pruneCache{Dir: t.TempDir()}
+
+	res1, need1 := c.Prune(snapshots, policy, time.UTC, nil)
+	if ents, _ := os.ReadDir(c.Dir); len(ents) != 1 {
+		t.Fatalf("expected one cached result, got %d", len(ents))
+	}
+	res2, need2 := c.Prune(snapshots, policy, time.UTC, nil)
+	if !reflect.DeepEqual(res1, res2) {
+		t.Errorf("cached result differs")
+	}
+	policy.Each(func(period snappr.Period, _ int) {
+		if a, b := need1.Get(period), need2.Get(period); a != b {
+			t.Errorf("cached need for %s differs: %d != %d", period, a, b)
+		}
+	})
+
+	var progress [][2]int
+	c.Prune(snapshots, policy, time.UTC, &snappr.PruneOptions{Progress: func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	}})
+	if exp := [][2]int{{400, 400}}; !reflect.DeepEqual(progress, exp) {
+		t.Errorf("expected progress %v for a cached result, got %v", exp, progress)
+	}
+
+	c.Prune(snapshots[1:], policy, time.UTC, nil)
+	c.Prune(snapshots, policy, time.UTC, &snappr.PruneOptions{MonthMode: snappr.FixedMonth})
+	if ents, _ := os.ReadDir(c.Dir); len(ents) != 3 {
+		t.Errorf("expected three cached results, got %d", len(ents))
+	}
+}
+
+func TestPruneCacheKey(t *testing.T) {
+	snapshots := []time.Time{time.Unix(1672531200, 0), time.Unix(1672617600, 0)}
+	policy, err := snappr.ParsePolicy("1@daily")
+	if err != nil {
+		panic(err)
+	}
+	loc := time.FixedZone("Test/Zone", 0)
+
+	key := func(tzdata fstest.MapFS, opt *snappr.PruneOptions) string {
+		var fsys fs.FS
+		if tzdata != nil {
+			fsys = tzdata
+		}
+		k, ok := pruneKey(snapshots, policy, loc, fsys, opt)
+		if !ok {
+			return ""
+		}
+		return k
+	}
+	a := key(fstest.MapFS{"Test/Zone": {Data: []byte("a")}}, nil)
+	b := key(fstest.MapFS{"Test/Zone": {Data: []byte("b")}}, nil)
+	if a == "" || a == b {
+		t.Errorf("expected the zone data to be hashed")
+	}
+	if a == key(nil, nil) {
+		t.Errorf("expected the key to differ with zone data")
+	}
+	if key(fstest.MapFS{"Other/Zone": {Data: []byte("a")}}, nil) != key(nil, nil) {
+		t.Errorf("expected the key to be the same if the zone isn't in the zone data")
+	}
+
+	if !policy.Set(snappr.Period{Unit: snappr.Yearly, Interval: 1, Zone: "Europe/Paris"}, 1) {
+		panic("failed to set period")
+	}
+	a = key(fstest.MapFS{"Europe/Paris": {Data: []byte("a")}}, nil)
+	b = key(fstest.MapFS{"Europe/Paris": {Data: []byte("b")}}, nil)
+	if a == "" || a == b {
+		t.Errorf("expected the zone data for periods to be hashed")
+	}
+
+	if key(nil, &snappr.PruneOptions{Workdays: holidays{}}) == "" {
+		t.Errorf("expected holidays to be hashed")
+	}
+	if key(nil, &snappr.PruneOptions{Workdays: snappr.WorkdaysFunc(func(time.Time) bool { return true })}) != "" {
+		t.Errorf("expected custom workdays to not be cached")
+	}
+	if a, b := key(nil, &snappr.PruneOptions{Calendar: snappr.Gregorian{}}), key(nil, &snappr.PruneOptions{Calendar: snappr.Gregorian{FiscalYearStart: time.April}}); a == "" || a == b {
+		t.Errorf("expected Gregorian calendars to be hashed")
+	}
+	if key(nil, &snappr.PruneOptions{Calendar: testCalendar{}}) != "" {
+		t.Errorf("expected custom calendars to not be cached")
+	}
+}
+
+func TestPruneCacheClean(t *testing.T) {
+	policy, err := snappr.ParsePolicy("1@daily")
+	if err != nil {
+		panic(err)
+	}
+	c := pruneCache{Dir: t.TempDir(), TTL: time.Hour}
+
+	c.Prune([]time.Time{time.Unix(1672531200, 0)}, policy, time.UTC, nil)
+	c.Prune([]time.Time{time.Unix(1672617600, 0)}, policy, time.UTC, nil)
+	ents, _ := os.ReadDir(c.Dir)
+	if len(ents) != 2 {
+		t.Fatalf("expected two cached results, got %d", len(ents))
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(c.Dir, ents[0].Name()), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Clean(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if after, _ := os.ReadDir(c.Dir); len(after) != 1 || after[0].Name() != ents[1].Name() {
+		t.Errorf("expected only the unused result to be removed, got %v", after)
+	}
+}
+
+type testCalendar struct{ snappr.Gregorian }
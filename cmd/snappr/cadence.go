@@ -0,0 +1,105 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	cadenceWindow = 8 // number of intervals used to determine the usual cadence
+	cadenceFactor = 3 // how much longer than usual an interval must be to be a gap, or how much the cadence must change
+)
+
+// cadenceAnomaly is an irregularity in the cadence of a set of snapshots.
+type cadenceAnomaly struct {
+	Change        bool          // if false, it's a gap
+	From, To      time.Time     // the gap, or the time the cadence changed (only From is set)
+	Before, After time.Duration // the usual interval before and after (only Before is set for a gap)
+}
+
+// detectCadence detects gaps and changes in the interval between snapshots,
+// which must be sorted ascending.
+func detectCadence(times []time.Time) []cadenceAnomaly {
+	if len(times) < 2 {
+		return nil
+	}
+	gaps := make([]time.Duration, len(times)-1)
+	for i := range gaps {
+		gaps[i] = times[i+1].Sub(times[i])
+	}
+
+	var anomalies []cadenceAnomaly
+	for i := cadenceWindow; i < len(gaps); i++ {
+		before := medianDuration(gaps[i-cadenceWindow : i])
+		if before <= 0 {
+			continue // duplicate timestamps
+		}
+		if i+cadenceWindow <= len(gaps) {
+			if after := medianDuration(gaps[i : i+cadenceWindow]); differsBy(before, after, cadenceFactor) {
+				// the median changes before the new cadence actually starts, so
+				// find the first interval closer to it
+				j := i
+				for j < len(gaps)-1 && !closerTo(gaps[j], after, before) {
+					j++
+				}
+				anomalies = append(anomalies, cadenceAnomaly{
+					Change: true,
+					From:   times[j],
+					Before: before,
+					After:  medianDuration(gaps[j:min(j+cadenceWindow, len(gaps))]),
+				})
+				i = j + cadenceWindow - 1
+				continue
+			}
+		}
+		if gaps[i] > before*cadenceFactor {
+			anomalies = append(anomalies, cadenceAnomaly{
+				From:   times[i],
+				To:     times[i+1],
+				Before: before,
+			})
+		}
+	}
+	return anomalies
+}
+
+func medianDuration(ds []time.Duration) time.Duration {
+	s := slices.Clone(ds)
+	slices.Sort(s)
+	if len(s)%2 == 0 {
+		return (s[len(s)/2-1] + s[len(s)/2]) / 2
+	}
+	return s[len(s)/2]
+}
+
+// differsBy checks whether a and b differ by at least a factor of f.
+func differsBy(a, b time.Duration, f int64) bool {
+	return a*time.Duration(f) <= b || b*time.Duration(f) <= a
+}
+
+// closerTo checks whether d is proportionally closer to a than b.
+func closerTo(d, a, b time.Duration) bool {
+	ra := float64(max(d, a)) / float64(max(min(d, a), 1))
+	rb := float64(max(d, b)) / float64(max(min(d, b), 1))
+	return ra < rb
+}
+
+// snapshotGap is the time between consecutive snapshots.
+type snapshotGap struct {
+	From, To time.Time
+}
+
+// Duration returns the length of the gap.
+func (g snapshotGap) Duration() time.Duration {
+	return g.To.Sub(g.From)
+}
+
+// snapshotGaps returns the gaps between consecutive snapshots, which must be
+// sorted ascending.
+func snapshotGaps(times []time.Time) []snapshotGap {
+	var gaps []snapshotGap
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, snapshotGap{times[i-1], times[i]})
+	}
+	return gaps
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/buildkite/shellwords"
+	"github.com/spf13/pflag"
+)
+
+// configOption is an option read from a --config file.
+type configOption struct {
+	Line   int
+	Name   string // long option name, or policy
+	Values []string
+}
+
+// readConfig reads a config file, where each line is the name of a long
+// option followed by its values (if any), using shell quoting (e.g., extract
+// '^([0-9]+)'). A policy line contains policy rules. Empty lines and lines
+// starting with # are ignored.
+func readConfig(name string) ([]configOption, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var opts []configOption
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words, err := shellwords.Split(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		opts = append(opts, configOption{
+			Line:   n,
+			Name:   strings.TrimPrefix(words[0], "--"),
+			Values: words[1:],
+		})
+	}
+	return opts, sc.Err()
+}
+
+// applyConfig sets the options which were not set on the command line,
+// returning the policy rules.
+func applyConfig(opt *pflag.FlagSet, opts []configOption) ([]string, error) {
+	set := map[string]bool{}
+	opt.Visit(func(f *pflag.Flag) {
+		set[f.Name] = true
+	})
+
+	var rules []string
+	for _, o := range opts {
+		if o.Name == "policy" {
+			rules = append(rules, o.Values...)
+			continue
+		}
+		f := opt.Lookup(o.Name)
+		if f == nil || o.Name == "config" || o.Name == "help" {
+			return nil, fmt.Errorf("line %d: unknown option %q", o.Line, o.Name)
+		}
+		if set[o.Name] {
+			continue // overridden on the command line
+		}
+		if len(o.Values) == 0 {
+			if f.NoOptDefVal == "" {
+				return nil, fmt.Errorf("line %d: option %q requires a value", o.Line, o.Name)
+			}
+			o.Values = []string{f.NoOptDefVal}
+		}
+		for _, v := range o.Values {
+			if err := opt.Set(o.Name, v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid value %q for option %q: %w", o.Line, v, o.Name, err)
+			}
+		}
+	}
+	return rules, nil
+}
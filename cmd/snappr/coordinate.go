@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// coordinator exchanges decision hashes between hosts pruning the same
+// snapshots, so they only act once all of them agree.
+//
+// Each host writes its hash to Location/Host (a file in a shared directory, or
+// a PUT request to an http(s) URL), then polls for the hashes of the other
+// hosts. The location should be unique for each run (e.g., by including the
+// date) so stale hashes from previous runs are not used.
+type coordinator struct {
+	Location string        // directory or http(s) URL
+	Host     string        // name of this host
+	Hosts    []string      // names of all hosts, including this one
+	Poll     time.Duration // how often to check for the other hashes
+	Client   *http.Client  // if nil, a default client with a timeout is used
+}
+
+// errCoordinateDisagree is returned if the hosts submitted different hashes.
+var errCoordinateDisagree = errors.New("hosts do not agree on the decisions")
+
+// Coordinate submits the hash for this host, then waits until every host has
+// submitted one or the context is done. If the hashes are different, the
+// returned error wraps errCoordinateDisagree.
+func (c *coordinator) Coordinate(ctx context.Context, hash string) error {
+	if err := c.put(ctx, c.Host, hash); err != nil {
+		return fmt.Errorf("submit hash: %w", err)
+	}
+	pending := map[string]bool{}
+	for _, host := range c.Hosts {
+		pending[host] = host != c.Host
+	}
+	var differ []string
+	for {
+		for _, host := range c.Hosts {
+			if !pending[host] {
+				continue
+			}
+			other, err := c.get(ctx, host)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("get hash for %s: %w", host, err)
+			}
+			if pending[host] = false; other != hash {
+				differ = append(differ, host)
+			}
+		}
+		var waiting []string
+		for _, host := range c.Hosts {
+			if pending[host] {
+				waiting = append(waiting, host)
+			}
+		}
+		if len(waiting) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", strings.Join(waiting, ", "), ctx.Err())
+		case <-time.After(c.Poll):
+		}
+	}
+	if len(differ) != 0 {
+		return fmt.Errorf("%w (%s)", errCoordinateDisagree, strings.Join(differ, ", "))
+	}
+	return nil
+}
+
+func (c *coordinator) put(ctx context.Context, host, hash string) error {
+	if !isRemotePolicy(c.Location) {
+		name := filepath.Join(c.Location, host)
+		if err := os.MkdirAll(c.Location, 0777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name+".tmp", []byte(hash+"\n"), 0666); err != nil {
+			return err
+		}
+		return os.Rename(name+".tmp", name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(host), strings.NewReader(hash+"\n"))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// get returns the hash submitted by the host, or an error wrapping
+// fs.ErrNotExist if it hasn't submitted one yet.
+func (c *coordinator) get(ctx context.Context, host string) (string, error) {
+	if !isRemotePolicy(c.Location) {
+		buf, err := os.ReadFile(filepath.Join(c.Location, host))
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.TrimSpace(buf)), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(host), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fs.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(buf)), nil
+}
+
+func (c *coordinator) url(host string) string {
+	return strings.TrimSuffix(c.Location, "/") + "/" + url.PathEscape(host)
+}
+
+func (c *coordinator) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: time.Second * 30}
+}
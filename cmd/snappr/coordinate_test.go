@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinate(t *testing.T) {
+	run := func(dir string, inputs map[string]string, extra ...string) (map[string]int, map[string]string) {
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			status = map[string]int{}
+			output = map[string]string{}
+		)
+		for host, input := range inputs {
+			wg.Add(1)
+			go func(host, input string) {
+				defer wg.Done()
+				var stdout, stderr bytes.Buffer
+				args := append([]string{"snappr", "coordinate", "--coordinator", dir, "--host", host, "--hosts", "a,b", "-q"}, extra...)
+				n := Main(append(args, "2@daily"), strings.NewReader(input), &stdout, &stderr)
+				mu.Lock()
+				defer mu.Unlock()
+				status[host], output[host] = n, stdout.String()
+			}(host, input)
+		}
+		wg.Wait()
+		return status, output
+	}
+
+	status, output := run(t.TempDir(), map[string]string{
+		"a": "1672531200\n1672617600\n1672704000\n",
+		"b": "1672531200\n1672617600\n1672704000\n",
+	})
+	for _, host := range []string{"a", "b"} {
+		if status[host] != 0 || output[host] != "1672531200\n" {
+			t.Errorf("agree: host %s: expected status 0 and output, got status %d and output %q", host, status[host], output[host])
+		}
+	}
+
+	status, output = run(t.TempDir(), map[string]string{
+		"a": "1672531200\n1672617600\n1672704000\n",
+		"b": "1672531200\n1672617600\n1672704000\n1672790400\n",
+	})
+	for _, host := range []string{"a", "b"} {
+		if status[host] != 3 || output[host] != "" {
+			t.Errorf("disagree: host %s: expected status 3 and no output, got status %d and output %q", host, status[host], output[host])
+		}
+	}
+
+	status, output = run(t.TempDir(), map[string]string{
+		"a": "1672531200\n1672617600\n1672704000\n",
+	}, "--coordinate-timeout", "10ms")
+	if status["a"] != 3 || output["a"] != "" {
+		t.Errorf("timeout: expected status 3 and no output, got status %d and output %q", status["a"], output["a"])
+	}
+}
+
+func TestCoordinatorRemote(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		hashes = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			buf, _ := io.ReadAll(r.Body)
+			hashes[r.URL.Path] = string(buf)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			if h, ok := hashes[r.URL.Path]; ok {
+				io.WriteString(w, h)
+			} else {
+				http.NotFound(w, r)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	coordinate := func(host, hash string) error {
+		c := &coordinator{
+			Location: srv.URL + "/run/",
+			Host:     host,
+			Hosts:    []string{"a", "b"},
+			Poll:     time.Millisecond,
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return c.Coordinate(ctx, hash)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- coordinate("a", "abc") }()
+	go func() { errs <- coordinate("b", "abc") }()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("agree: unexpected error: %v", err)
+		}
+	}
+	if hashes["/run/a"] != "abc\n" {
+		t.Errorf("expected hash to be submitted to the host path, got %q", hashes)
+	}
+
+	if err := coordinate("a", "def"); !errors.Is(err, errCoordinateDisagree) {
+		t.Errorf("disagree: expected errCoordinateDisagree, got %v", err)
+	}
+}
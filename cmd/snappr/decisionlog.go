@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// decisionRecord is a single line of the --decision-log.
+type decisionRecord struct {
+	Prev     string    `json:"prev"` // hex sha256 of the previous line, or empty for the first one
+	Run      time.Time `json:"run"`  // the reference time of the run
+	Group    string    `json:"group,omitempty"`
+	Time     time.Time `json:"time"`
+	Decision string    `json:"decision"` // keep, prune, or quarantine
+	Reasons  []string  `json:"reasons,omitempty"`
+	Line     string    `json:"line"`
+}
+
+// errDecisionLogChain is returned if the hash chain of a decision log is
+// broken.
+var errDecisionLogChain = errors.New("hash chain is broken")
+
+// verifyDecisionLog checks the hash chain of a decision log, returning the hash
+// of the last line.
+func verifyDecisionLog(r io.Reader) (string, error) {
+	var (
+		prev string
+		n    int
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16*1024*1024)
+	for sc.Scan() {
+		n++
+		var rec decisionRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return "", fmt.Errorf("line %d: %w", n, err)
+		}
+		if rec.Prev != prev {
+			return "", fmt.Errorf("line %d: %w", n, errDecisionLogChain)
+		}
+		sum := sha256.Sum256(sc.Bytes())
+		prev = hex.EncodeToString(sum[:])
+	}
+	return prev, sc.Err()
+}
+
+// appendDecisionLog verifies the decision log, then appends the records to it,
+// creating it if it doesn't exist.
+func appendDecisionLog(name string, recs []decisionRecord) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	prev, err := verifyDecisionLog(f)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		rec.Prev = prev
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(line)
+		prev = hex.EncodeToString(sum[:])
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecisionLog(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	run := func(args ...string) int {
+		var stdout, stderr bytes.Buffer
+		return Main(append([]string{"snappr", "--decision-log", log}, args...), strings.NewReader("1672531200\n1672617600\n1672704000\ninvalid\n"), &stdout, &stderr)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status := run("--now", "2023-01-04T00:00:00Z", "-q", "2@daily"); status != 0 {
+			t.Fatalf("run %d: unexpected exit status %d", i, status)
+		}
+	}
+	if status := run("--verify-decision-log"); status != 0 {
+		t.Fatalf("verify: unexpected exit status %d", status)
+	}
+
+	buf, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("read decision log: %v", err)
+	}
+	if n := strings.Count(string(buf), "\n"); n != 6 {
+		t.Errorf("expected 6 records, got %d", n)
+	}
+	if n := strings.Count(string(buf), `"decision":"prune"`); n != 2 {
+		t.Errorf("expected 2 prune records, got %d", n)
+	}
+	if !strings.HasPrefix(string(buf), `{"prev":"",`) {
+		t.Errorf("expected first record to have an empty prev hash")
+	}
+
+	if err := os.WriteFile(log, bytes.Replace(buf, []byte(`"decision":"prune"`), []byte(`"decision":"keep"`), 1), 0666); err != nil {
+		t.Fatalf("write decision log: %v", err)
+	}
+	if status := run("--verify-decision-log"); status != 3 {
+		t.Errorf("verify tampered: expected exit status 3, got %d", status)
+	}
+	if status := run("-q", "2@daily"); status != 3 {
+		t.Errorf("append to tampered: expected exit status 3, got %d", status)
+	}
+}
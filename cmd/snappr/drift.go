@@ -0,0 +1,24 @@
+package main
+
+import "time"
+
+// driftVanished marks the snapshots in the old listing (the from range of
+// lines) which are not in the new one (the to range) as vanished. Snapshots
+// which are still present have their time zeroed so they are ignored, since the
+// policy is evaluated against the union of both listings, and snapshots which
+// were pruned normally as newer ones were taken shouldn't be reported.
+func driftVanished(lines *lineStore, times []time.Time, from, to [2]int) []bool {
+	inNew := map[string]bool{}
+	for i := to[0]; i < to[1]; i++ {
+		inNew[lines.Get(i)] = true
+	}
+	vanished := make([]bool, len(times))
+	for i := from[0]; i < from[1]; i++ {
+		if inNew[lines.Get(i)] {
+			times[i] = time.Time{} // already in new
+		} else {
+			vanished[i] = true
+		}
+	}
+	return vanished
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strconv"
+)
+
+// diskUsage computes the space used by file trees, counting each hard-linked
+// file (e.g., in trees created with rsync --link-dest) only once.
+type diskUsage struct {
+	seen map[fileKey]bool
+}
+
+// fileKey uniquely identifies a file on a system.
+type fileKey struct {
+	Dev, Ino uint64
+}
+
+// Add walks the tree at path, returning the size of the files which have not
+// already been seen.
+func (du *diskUsage) Add(path string) (int64, error) {
+	if du.seen == nil {
+		du.seen = map[fileKey]bool{}
+	}
+	var n int64
+	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		key, size, ok := fileUsage(fi)
+		if ok {
+			if du.seen[key] {
+				return nil
+			}
+			du.seen[key] = true
+		}
+		n += size
+		return nil
+	})
+	return n, err
+}
+
+// formatSize formats a size in bytes using binary units.
+func formatSize(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	v, i := float64(n)/1024, 0
+	for ; v >= 1024 && i < len(units)-1; i++ {
+		v /= 1024
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + string(units[i]) + "iB"
+}
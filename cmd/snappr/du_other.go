@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileUsage gets the apparent size of a file. Hard links are not detected on
+// this platform.
+func fileUsage(fi fs.FileInfo) (fileKey, int64, bool) {
+	return fileKey{}, fi.Size(), false
+}
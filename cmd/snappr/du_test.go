@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not detected on windows")
+	}
+	dir := t.TempDir()
+
+	// each tree links the unchanged files from the previous one, like rsync
+	// --link-dest, and adds a new file
+	var stdin bytes.Buffer
+	for i, name := range []string{"2024-01-01", "2024-01-02", "2024-01-03"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0777); err != nil {
+			panic(err)
+		}
+		if i != 0 {
+			prev := filepath.Join(dir, []string{"2024-01-01", "2024-01-02"}[i-1])
+			ents, err := os.ReadDir(prev)
+			if err != nil {
+				panic(err)
+			}
+			for _, ent := range ents {
+				if err := os.Link(filepath.Join(prev, ent.Name()), filepath.Join(dir, name, ent.Name())); err != nil {
+					panic(err)
+				}
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, name), bytes.Repeat([]byte{'x'}, 64<<10), 0666); err != nil {
+			panic(err)
+		}
+		stdin.WriteString(filepath.Join(dir, name) + "\n")
+	}
+
+	var (
+		du        diskUsage
+		reclaimed int64
+	)
+	first, err := du.Add(filepath.Join(dir, "2024-01-03"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first < 3*64<<10 {
+		t.Errorf("expected at least the size of the three files, got %d", first)
+	}
+	if n, err := du.Add(filepath.Join(dir, "2024-01-02")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n >= 64<<10 {
+		t.Errorf("expected hard-linked files to be counted once, got %d", n)
+	} else {
+		reclaimed = n
+	}
+	if n, err := du.Add(filepath.Join(dir, "2024-01-01")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else {
+		reclaimed += n
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := Main([]string{"snappr", "-s", "--disk-usage", "-e", "[0-9-]+$", "-p", "2006-01-02", "1@daily"}, &stdin, &stdout, &stderr); status != 0 {
+		t.Fatalf("unexpected exit status %d: %s", status, stderr.String())
+	}
+	if act, exp := stderr.String(), "snappr: summary: reclaiming "+formatSize(reclaimed)+"/"+formatSize(first+reclaimed)+"\n"; !strings.HasSuffix(act, exp) {
+		t.Errorf("expected summary to end with %q, got %q", exp, act)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileUsage gets the identity and allocated size of a file.
+func fileUsage(fi fs.FileInfo) (fileKey, int64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, fi.Size(), false
+	}
+	return fileKey{uint64(st.Dev), uint64(st.Ino)}, int64(st.Blocks) * 512, true
+}
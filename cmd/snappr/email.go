@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// emailReport sends a report using SMTP or sendmail.
+type emailReport struct {
+	From     string
+	To       []string
+	SMTP     string // smtp:// or smtps:// URL with optional credentials; if empty, sendmail is used
+	Sendmail string // sendmail binary
+}
+
+// reportSubject returns the subject for a report of a run which exited with
+// the specified status.
+func reportSubject(status int) string {
+	host, _ := os.Hostname()
+	switch status {
+	case 0:
+		return fmt.Sprintf("snappr: ok on %s", host)
+	case 3:
+		return fmt.Sprintf("snappr: policy violations on %s", host)
+	case 4:
+		return fmt.Sprintf("snappr: missing snapshots on %s", host)
+	default:
+		return fmt.Sprintf("snappr: failed on %s (exit status %d)", host, status)
+	}
+}
+
+// Message formats an email message.
+func (e emailReport) Message(subject string, body []byte, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "\r\n")
+	if len(body) == 0 {
+		body = []byte("(no output)\n")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// Send sends the report.
+func (e emailReport) Send(subject string, body []byte) error {
+	msg := e.Message(subject, body, time.Now())
+	if e.SMTP == "" {
+		cmd := exec.Command(e.Sendmail, "-t", "-i")
+		cmd.Stdin = bytes.NewReader(msg)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("sendmail: %w (output: %q)", err, out)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(e.SMTP)
+	if err != nil {
+		return fmt.Errorf("parse smtp url: %w", err)
+	}
+	host, port := u.Hostname(), u.Port()
+	switch u.Scheme {
+	case "smtp":
+		if port == "" {
+			port = "25"
+		}
+	case "smtps":
+		if port == "" {
+			port = "465"
+		}
+	default:
+		return fmt.Errorf("unsupported smtp url scheme %q", u.Scheme)
+	}
+	addr := net.JoinHostPort(host, port)
+
+	var auth smtp.Auth
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth = smtp.PlainAuth("", u.User.Username(), pass, host)
+	}
+	if u.Scheme == "smtp" {
+		return smtp.SendMail(addr, auth, e.From, e.To, msg) // uses STARTTLS if available
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
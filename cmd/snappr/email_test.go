@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailMessage(t *testing.T) {
+	e := emailReport{
+		From: "snappr@example.com",
+		To:   []string{"a@example.com", "b@example.com"},
+	}
+	act := string(e.Message("snappr: ok on host", []byte("line 1\nline 2\n"), time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)))
+	exp := strings.Join([]string{
+		"From: snappr@example.com",
+		"To: a@example.com, b@example.com",
+		"Subject: snappr: ok on host",
+		"Date: Sat, 01 Jun 2024 12:00:00 +0000",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"line 1",
+		"line 2",
+		"",
+	}, "\r\n")
+	if act != exp {
+		t.Errorf("incorrect message:\n%q\nexpected:\n%q", act, exp)
+	}
+}
+
+func TestEmailSendmail(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "message")
+	sendmail := filepath.Join(dir, "sendmail")
+	if err := os.WriteFile(sendmail, []byte("#!/bin/sh\ncat > '"+out+"'\n"), 0777); err != nil {
+		panic(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := Main([]string{"snappr", "--email-to", "root@example.com", "--sendmail", sendmail, "-s", "--require-satisfied", "2@daily"}, strings.NewReader("1672531200\n"), &stdout, &stderr)
+	if status != 4 {
+		t.Fatalf("expected exit status 4, got %d (stderr: %s)", status, stderr.String())
+	}
+
+	buf, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expected message to be sent: %v", err)
+	}
+	msg := string(buf)
+	if !strings.Contains(msg, "To: root@example.com\r\n") {
+		t.Errorf("expected message to be sent to root@example.com, got:\n%s", msg)
+	}
+	if !strings.Contains(msg, "Subject: snappr: missing snapshots on ") {
+		t.Errorf("expected missing snapshots subject, got:\n%s", msg)
+	}
+	if !strings.Contains(msg, "\r\nsnappr: summary: (2) 1 day (missing 1)\r\n") || !strings.Contains(msg, "\r\nsnappr: error: missing 1 snapshots for 1 day\r\n") {
+		t.Errorf("expected message to contain stderr, got:\n%s", msg)
+	}
+}
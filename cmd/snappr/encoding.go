@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// inputEncoding is a single-byte legacy encoding used to decode input lines
+// for matching regexps against them. A nil *inputEncoding is UTF-8, which is
+// used as-is (invalid bytes match as U+FFFD).
+type inputEncoding struct {
+	high *[128]rune // 0x80-0xFF, or nil for Latin-1
+}
+
+// windows1252 maps 0x80-0x9F to the Windows-1252 characters (the rest are the
+// same as Latin-1). Unassigned bytes are mapped to the C1 control characters.
+var windows1252 = func() *[128]rune {
+	var t [128]rune
+	for i := range t {
+		t[i] = rune(0x80 + i)
+	}
+	copy(t[:0x20], []rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+	})
+	return &t
+}()
+
+// parseInputEncoding gets an input encoding by name.
+func parseInputEncoding(name string) (*inputEncoding, error) {
+	switch strings.ReplaceAll(strings.ToLower(name), "_", "-") {
+	case "utf-8", "utf8":
+		return nil, nil
+	case "latin-1", "latin1", "iso-8859-1", "iso8859-1":
+		return &inputEncoding{}, nil
+	case "windows-1252", "cp1252":
+		return &inputEncoding{high: windows1252}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q (only utf-8, latin-1, and windows-1252 are supported)", name)
+	}
+}
+
+// Decode converts s to UTF-8, also returning the offset in s of each byte in
+// the result (and the end), or nil if the encoding is UTF-8.
+func (e *inputEncoding) Decode(s string) (string, []int) {
+	if e == nil {
+		return s, nil
+	}
+	var (
+		b   []byte
+		off = make([]int, 0, len(s)+1)
+	)
+	for i := 0; i < len(s); i++ {
+		r := rune(s[i])
+		if r >= 0x80 && e.high != nil {
+			r = e.high[r-0x80]
+		}
+		n := len(b)
+		b = utf8.AppendRune(b, r)
+		for ; n < len(b); n++ {
+			off = append(off, i)
+		}
+	}
+	return string(b), append(off, len(s))
+}
+
+// rawOffset converts an offset in a string returned by Decode back to an
+// offset in the original string.
+func rawOffset(off []int, i int) int {
+	if off == nil {
+		return i
+	}
+	return off[i]
+}
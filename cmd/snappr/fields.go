@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// field is a named value extracted from each input line with --field.
+type field struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// templateKeys are the built-in keys for --template, which can't be used as
+// field names.
+var templateKeys = []string{"line", "time", "reasons", "group"}
+
+// parseField parses a NAME=REGEXP field definition, where the regexp has the
+// same syntax as --extract.
+func parseField(s string, extended bool) (field, error) {
+	name, expr, ok := strings.Cut(s, "=")
+	if !ok {
+		return field{}, fmt.Errorf("expected NAME=REGEXP")
+	}
+	if !isFieldName(name) {
+		return field{}, fmt.Errorf("invalid name %q (must be a letter or underscore followed by letters, digits, or underscores)", name)
+	}
+	if slices.Contains(templateKeys, name) {
+		return field{}, fmt.Errorf("name %q is reserved", name)
+	}
+	re, err := compileRegexp("", expr, extended, true)
+	if err != nil {
+		return field{}, err
+	}
+	return field{Name: name, Regexp: re}, nil
+}
+
+// compileRegexp compiles the regexp for a flag, using full regexp syntax if
+// extended, or POSIX syntax otherwise. If single, it must not contain more than
+// one capture group. If flagName is empty, the error doesn't mention it.
+func compileRegexp(flagName, expr string, extended, single bool) (*regexp.Regexp, error) {
+	var (
+		re  *regexp.Regexp
+		err error
+	)
+	if extended {
+		re, err = regexp.Compile(expr)
+	} else {
+		re, err = regexp.CompilePOSIX(expr)
+	}
+	if err == nil && single && re.NumSubexp() > 1 {
+		err = fmt.Errorf("must contain no more than one capture group")
+	}
+	if err != nil {
+		if flagName == "" {
+			return nil, fmt.Errorf("regexp is invalid: %w", err)
+		}
+		return nil, fmt.Errorf("--%s regexp is invalid: %w", flagName, err)
+	}
+	return re, nil
+}
+
+// Extract returns the part of the text matched by the field's regexp (or its
+// capture group), or an empty string if it doesn't match.
+func (f field) Extract(text string) string {
+	if m := f.Regexp.FindStringSubmatch(text); m != nil {
+		return m[len(m)-1]
+	}
+	return ""
+}
+
+func isFieldName(s string) bool {
+	for i, c := range s {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i != 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// outputTemplate formats output lines for --template. It alternates between
+// literal text and keys, starting with literal text.
+type outputTemplate []string
+
+// parseTemplate parses a template where {KEY} is replaced by the value of KEY,
+// and {{ and }} are literal braces. All keys must be valid.
+func parseTemplate(s string, valid func(key string) bool) (outputTemplate, error) {
+	var (
+		t   outputTemplate
+		lit strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case (c == '{' || c == '}') && i+1 < len(s) && s[i+1] == c:
+			lit.WriteByte(c)
+			i++
+		case c == '{':
+			n := strings.IndexByte(s[i:], '}')
+			if n == -1 {
+				return nil, fmt.Errorf("unterminated {")
+			}
+			key := s[i+1 : i+n]
+			if !valid(key) {
+				return nil, fmt.Errorf("unknown key {%s}", key)
+			}
+			t = append(t, lit.String(), key)
+			lit.Reset()
+			i += n
+		case c == '}':
+			return nil, fmt.Errorf("unexpected } (use }} for a literal one)")
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	return append(t, lit.String()), nil
+}
+
+// Execute formats the template using the values of the keys.
+func (t outputTemplate) Execute(value func(key string) string) string {
+	var b strings.Builder
+	for i, s := range t {
+		if i%2 == 1 {
+			s = value(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
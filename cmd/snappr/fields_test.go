@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	valid := func(key string) bool {
+		return key == "a" || key == "b"
+	}
+	for tmpl, exp := range map[string]string{
+		"":              "",
+		"x":             "x",
+		"{a}":           "A",
+		"{a}-{b}{a}":    "A-BA",
+		"{{a}} {{{b}}}": "{a} {B}",
+		"{c}":           "",
+		"{a":            "",
+		"a}":            "",
+	} {
+		v, err := parseTemplate(tmpl, valid)
+		if exp == "" && tmpl != "" {
+			if err == nil {
+				t.Errorf("parse %q: expected error", tmpl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse %q: unexpected error: %v", tmpl, err)
+			continue
+		}
+		act := v.Execute(func(key string) string {
+			return map[string]string{"a": "A", "b": "B"}[key]
+		})
+		if act != exp {
+			t.Errorf("execute %q: expected %q, got %q", tmpl, exp, act)
+		}
+	}
+}
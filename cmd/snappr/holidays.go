@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pgaskin/snappr"
+)
+
+// holidays is a [snappr.Workdays] where Monday through Friday are working days,
+// other than the contained dates (YYYY-MM-DD).
+type holidays map[string]bool
+
+// readHolidays reads a list of dates (YYYY-MM-DD), one per line, with blank
+// lines and # comments ignored.
+func readHolidays(r io.Reader) (holidays, error) {
+	h := holidays{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", n, line)
+		}
+		h[t.Format(time.DateOnly)] = true
+	}
+	return h, sc.Err()
+}
+
+// IsWorkday implements [snappr.Workdays].
+func (h holidays) IsWorkday(t time.Time) bool {
+	return snappr.Weekdays{}.IsWorkday(t) && !h[t.Format(time.DateOnly)]
+}
+
+// String returns the sorted dates, comma-separated.
+func (h holidays) String() string {
+	dates := make([]string, 0, len(h))
+	for d := range h {
+		dates = append(dates, d)
+	}
+	slices.Sort(dates)
+	return strings.Join(dates, ",")
+}
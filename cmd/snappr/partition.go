@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+)
+
+// partition is a Hive-style partition path like table/dt=2024-06-01/region=eu.
+type partition struct {
+	Table string      // path components before the first key=value one, joined with "."
+	Keys  [][2]string // key=value components, in order
+}
+
+// parsePartition parses a partition path.
+func parsePartition(s string) (p partition) {
+	var table []string
+	for _, c := range strings.Split(strings.Trim(s, "/"), "/") {
+		if k, v, ok := strings.Cut(c, "="); ok {
+			p.Keys = append(p.Keys, [2]string{k, v})
+		} else if len(p.Keys) == 0 && c != "" {
+			table = append(table, c)
+		}
+	}
+	p.Table = strings.Join(table, ".")
+	return
+}
+
+// Get gets the value of the specified key.
+func (p partition) Get(key string) (string, bool) {
+	for _, kv := range p.Keys {
+		if kv[0] == key {
+			return kv[1], true
+		}
+	}
+	return "", false
+}
+
+// Group returns the table and all keys other than the specified one, for
+// grouping partitions by the remaining dimensions.
+func (p partition) Group(key string) string {
+	var b strings.Builder
+	b.WriteString(p.Table)
+	for _, kv := range p.Keys {
+		if kv[0] != key {
+			b.WriteByte('/')
+			b.WriteString(kv[0])
+			b.WriteByte('=')
+			b.WriteString(kv[1])
+		}
+	}
+	return b.String()
+}
+
+// DropSQL returns a statement to drop the partition from its table.
+func (p partition) DropSQL() string {
+	var b strings.Builder
+	b.WriteString("ALTER TABLE ")
+	b.WriteString(p.Table)
+	b.WriteString(" DROP IF EXISTS PARTITION (")
+	for i, kv := range p.Keys {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(kv[0])
+		b.WriteString(" = '")
+		b.WriteString(strings.ReplaceAll(kv[1], "'", "''"))
+		b.WriteString("'")
+	}
+	b.WriteString(");")
+	return b.String()
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// policyReader reads policy files from local paths or http(s) URLs.
+type policyReader struct {
+	Lookup   func(string) (string, bool)   // for variables in include paths
+	CacheDir string                        // if empty, remote files are not cached
+	CacheTTL time.Duration                 // max age of cached remote files before fetching again
+	Client   *http.Client                  // if nil, a default client with a timeout is used
+	Warn     func(format string, a ...any) // if not nil, called when a stale cached copy is used
+}
+
+// maxPolicySize is the maximum size of a remote policy file.
+const maxPolicySize = 1 << 20
+
+// Read reads the rules from a policy file, recursively processing include
+// directives. Include paths are relative to the file (or URL) containing them,
+// and may contain variables. Remote files may only include other remote files.
+// The stack contains the absolute paths or URLs of the files currently being
+// read, and is used to detect cycles.
+func (r *policyReader) Read(name string, stack []string) ([]string, error) {
+	remote := isRemotePolicy(name)
+
+	abs := name
+	if !remote {
+		var err error
+		if abs, err = filepath.Abs(name); err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+	}
+	stack = append(stack, abs)
+
+	var (
+		buf []byte
+		err error
+	)
+	if remote {
+		buf, err = r.fetch(name)
+	} else {
+		buf, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []string
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = stripComment(line)
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "include "); ok {
+			inc, err := expandVars(strings.TrimSpace(v), r.Lookup)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: include: %w", name, i+1, err)
+			}
+			if remote {
+				base, err := url.Parse(name)
+				if err != nil {
+					return nil, err
+				}
+				ref, err := url.Parse(inc)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: include: %w", name, i+1, err)
+				}
+				if inc = base.ResolveReference(ref).String(); !isRemotePolicy(inc) {
+					return nil, fmt.Errorf("%s:%d: include: remote policy files may only include other remote files", name, i+1)
+				}
+			} else if !isRemotePolicy(inc) && !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(name), inc)
+			}
+			v, err := r.Read(inc, stack)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, v...)
+			continue
+		}
+		rules = append(rules, strings.Fields(line)...)
+	}
+	return rules, nil
+}
+
+// fetch gets a remote policy file, using the cached copy if it is newer than
+// the TTL, or if fetching it fails. If the URL has a #sha256=HEX fragment, the
+// contents must match the checksum.
+func (r *policyReader) fetch(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("fetch %s: unsupported scheme %q (use an http(s) URL, e.g., a pre-signed one for s3)", rawURL, u.Scheme)
+	}
+
+	var sum []byte
+	if u.Fragment != "" {
+		v, ok := strings.CutPrefix(u.Fragment, "sha256=")
+		if ok {
+			sum, err = hex.DecodeString(v)
+		}
+		if !ok || err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("fetch %s: invalid checksum fragment (must be sha256=HEX)", rawURL)
+		}
+		u.Fragment = ""
+	}
+	check := func(buf []byte) error {
+		if sum != nil {
+			if v := sha256.Sum256(buf); !bytes.Equal(v[:], sum) {
+				return fmt.Errorf("fetch %s: checksum mismatch (got sha256=%x)", rawURL, v)
+			}
+		}
+		return nil
+	}
+
+	var cache string
+	if r.CacheDir != "" {
+		k := sha256.Sum256([]byte(u.String()))
+		cache = filepath.Join(r.CacheDir, "policy-"+hex.EncodeToString(k[:]))
+	}
+	if cache != "" {
+		if fi, err := os.Stat(cache); err == nil && time.Since(fi.ModTime()) < r.CacheTTL {
+			if buf, err := os.ReadFile(cache); err == nil && check(buf) == nil {
+				return buf, nil
+			}
+		}
+	}
+
+	buf, err := r.get(u.String())
+	if err == nil {
+		err = check(buf)
+	}
+	if err != nil {
+		if cache != "" {
+			if v, cerr := os.ReadFile(cache); cerr == nil && check(v) == nil {
+				if r.Warn != nil {
+					r.Warn("using cached copy of %s: %v", rawURL, err)
+				}
+				return v, nil
+			}
+		}
+		return nil, err
+	}
+
+	if cache != "" {
+		if err := os.MkdirAll(r.CacheDir, 0777); err == nil {
+			if err := os.WriteFile(cache+".tmp", buf, 0666); err == nil {
+				os.Rename(cache+".tmp", cache)
+			}
+		}
+	}
+	return buf, nil
+}
+
+func (r *policyReader) get(u string) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 30}
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: response status %d", u, resp.StatusCode)
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u, err)
+	}
+	if len(buf) > maxPolicySize {
+		return nil, fmt.Errorf("fetch %s: policy file is larger than %d bytes", u, maxPolicySize)
+	}
+	return buf, nil
+}
+
+// stripComment removes a comment starting with # from a policy file line. The
+// # must be at the start of the line or after whitespace, so it doesn't remove
+// URL fragments (e.g., the checksum of an included file).
+func stripComment(line string) string {
+	for i, c := range line {
+		if c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isRemotePolicy checks if a policy file name is a URL rather than a path.
+func isRemotePolicy(name string) bool {
+	scheme, _, ok := strings.Cut(name, "://")
+	return ok && scheme != "" && !strings.ContainsAny(scheme, "/\\.")
+}
+
+// expandVars replaces ${NAME} and ${NAME:-DEFAULT} in s, returning an error if
+// a variable without a default is not set.
+func expandVars(s string, lookup func(string) (string, bool)) (string, error) {
+	var err error
+	v := os.Expand(s, func(name string) string {
+		name, def, hasDef := strings.Cut(name, ":-")
+		if v, ok := lookup(name); ok && (v != "" || !hasDef) {
+			return v
+		}
+		if !hasDef && err == nil {
+			err = fmt.Errorf("variable %q is not set", name)
+		}
+		return def
+	})
+	return v, err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyReaderRemote(t *testing.T) {
+	files := map[string]string{
+		"/base.policy": "1@last\n${DAILY:-7}@daily\n",
+		"/site.policy": "include base.policy\n6@monthly # comment\n",
+		"/loop.policy": "include loop.policy\n",
+	}
+	sum := sha256.Sum256([]byte(files["/base.policy"]))
+	files["/pinned.policy"] = "include base.policy#sha256=" + hex.EncodeToString(sum[:]) + " # pinned\n"
+	sum[0]++
+	files["/mispinned.policy"] = "include base.policy#sha256=" + hex.EncodeToString(sum[:]) + "\n"
+	sum[0]--
+	files["/large.policy"] = strings.Repeat("# padding\n", maxPolicySize/10+1)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if v, ok := files[r.URL.Path]; ok {
+			w.Write([]byte(v))
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var warnings int
+	pr := &policyReader{
+		Lookup: func(string) (string, bool) {
+			return "", false
+		},
+		CacheDir: t.TempDir(),
+		CacheTTL: time.Hour,
+		Warn: func(string, ...any) {
+			warnings++
+		},
+	}
+
+	rules, err := pr.Read(srv.URL+"/site.policy", nil)
+	if err != nil {
+		t.Fatalf("read: unexpected error: %v", err)
+	}
+	if exp := []string{"1@last", "${DAILY:-7}@daily", "6@monthly"}; !slices.Equal(rules, exp) {
+		t.Errorf("read: expected rules %q, got %q", exp, rules)
+	}
+	if requests != 2 {
+		t.Errorf("read: expected 2 requests, got %d", requests)
+	}
+
+	if _, err := pr.Read(srv.URL+"/site.policy", nil); err != nil {
+		t.Fatalf("read cached: unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("read cached: expected no more requests, got %d", requests-2)
+	}
+
+	if _, err := pr.Read(srv.URL+"/loop.policy", nil); err == nil {
+		t.Errorf("read cycle: expected error")
+	}
+
+	if _, err := pr.Read(srv.URL+"/base.policy#sha256="+hex.EncodeToString(sum[:]), nil); err != nil {
+		t.Errorf("read pinned: unexpected error: %v", err)
+	}
+	sum[0]++
+	if _, err := pr.Read(srv.URL+"/base.policy#sha256="+hex.EncodeToString(sum[:]), nil); err == nil {
+		t.Errorf("read pinned: expected checksum error")
+	}
+	if rules, err := pr.Read(srv.URL+"/pinned.policy", nil); err != nil {
+		t.Errorf("read pinned include: unexpected error: %v", err)
+	} else if exp := []string{"1@last", "${DAILY:-7}@daily"}; !slices.Equal(rules, exp) {
+		t.Errorf("read pinned include: expected rules %q, got %q", exp, rules)
+	}
+	if _, err := pr.Read(srv.URL+"/mispinned.policy", nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("read pinned include: expected checksum error, got %v", err)
+	}
+
+	if _, err := pr.Read(srv.URL+"/large.policy", nil); err == nil {
+		t.Errorf("read large: expected size error")
+	}
+
+	pr.CacheTTL = 0
+	files["/base.policy"] = "2@last\n"
+	if rules, err := pr.Read(srv.URL+"/base.policy", nil); err != nil {
+		t.Errorf("read expired: unexpected error: %v", err)
+	} else if exp := []string{"2@last"}; !slices.Equal(rules, exp) {
+		t.Errorf("read expired: expected rules %q, got %q", exp, rules)
+	}
+
+	srv.Close()
+	if rules, err := pr.Read(srv.URL+"/base.policy", nil); err != nil {
+		t.Errorf("read offline: expected stale cached copy to be used, got error: %v", err)
+	} else if exp := []string{"2@last"}; !slices.Equal(rules, exp) {
+		t.Errorf("read offline: expected rules %q, got %q", exp, rules)
+	}
+	if warnings != 1 {
+		t.Errorf("read offline: expected 1 warning, got %d", warnings)
+	}
+
+	if _, err := pr.Read("s3://bucket/policy", nil); err == nil {
+		t.Errorf("read s3: expected unsupported scheme error")
+	}
+}
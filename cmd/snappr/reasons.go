@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pgaskin/snappr"
+)
+
+// reasonMatcher matches periods against a list of rules, where rules
+// consisting of only a unit name (e.g., daily) match any period with that
+// unit, and other rules must match exactly (e.g., daily:7).
+type reasonMatcher struct {
+	units   []snappr.Unit
+	periods []snappr.Period
+}
+
+func parseReasonMatcher(rules []string) (*reasonMatcher, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	m := new(reasonMatcher)
+	for _, rule := range rules {
+		var unit snappr.Unit
+		if unit.UnmarshalText([]byte(strings.ToLower(rule))) == nil {
+			m.units = append(m.units, unit) // e.g., within, which requires an interval
+			continue
+		}
+		policy, err := snappr.ParsePolicy(rule)
+		if err != nil {
+			return nil, err
+		}
+		policy.Each(func(period snappr.Period, _ int) {
+			if !strings.ContainsAny(rule, ":+~/") {
+				m.units = append(m.units, period.Unit)
+			} else {
+				m.periods = append(m.periods, period)
+			}
+		})
+	}
+	return m, nil
+}
+
+// Match checks whether the period matches any of the rules.
+func (m *reasonMatcher) Match(period snappr.Period) bool {
+	return slices.Contains(m.units, period.Unit) || slices.Contains(m.periods, period)
+}
+
+// reasonFilter filters snapshots by their reasons. If only is set, all reasons
+// must match it. If except is set, none may.
+type reasonFilter struct {
+	only, except *reasonMatcher
+}
+
+func parseReasonFilter(only, except []string) (reasonFilter, error) {
+	var (
+		f   reasonFilter
+		err error
+	)
+	if f.only, err = parseReasonMatcher(only); err != nil {
+		return f, fmt.Errorf("invalid --only-reason: %w", err)
+	}
+	if f.except, err = parseReasonMatcher(except); err != nil {
+		return f, fmt.Errorf("invalid --except-reason: %w", err)
+	}
+	return f, nil
+}
+
+// IsZero checks whether the filter allows everything.
+func (f reasonFilter) IsZero() bool {
+	return f.only == nil && f.except == nil
+}
+
+// Allow checks whether a snapshot with the specified reasons passes the
+// filter.
+func (f reasonFilter) Allow(reasons []snappr.Period) bool {
+	if f.only != nil && len(reasons) == 0 {
+		return false
+	}
+	for _, period := range reasons {
+		if f.only != nil && !f.only.Match(period) {
+			return false
+		}
+		if f.except != nil && f.except.Match(period) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// recordScanner reads records from an input, where each record starts with a
+// line matching a separator regexp and continues until the next one. Lines
+// before the first separator are a record of their own. If the separator is
+// nil, each line is a record.
+type recordScanner struct {
+	sc   *bufio.Scanner
+	sep  *regexp.Regexp
+	enc  *inputEncoding // for matching sep
+	rec  string
+	next *string // the separator line starting the next record, if read
+}
+
+func newRecordScanner(r io.Reader, sep *regexp.Regexp, enc *inputEncoding) *recordScanner {
+	return &recordScanner{sc: bufio.NewScanner(r), sep: sep, enc: enc}
+}
+
+// Scan advances to the next record.
+func (s *recordScanner) Scan() bool {
+	if s.sep == nil {
+		if !s.sc.Scan() {
+			return false
+		}
+		s.rec = s.sc.Text()
+		return true
+	}
+	var (
+		b  strings.Builder
+		ok bool
+	)
+	if s.next != nil {
+		b.WriteString(*s.next)
+		s.next, ok = nil, true
+	}
+	for s.sc.Scan() {
+		line := s.sc.Text()
+		if text, _ := s.enc.Decode(line); ok && s.sep.MatchString(text) {
+			s.next = &line
+			break
+		}
+		if ok {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+		ok = true
+	}
+	s.rec = strings.Trim(b.String(), "\n")
+	return ok
+}
+
+// Text returns the current record, with lines separated by newlines and
+// leading and trailing blank lines removed.
+func (s *recordScanner) Text() string {
+	return s.rec
+}
+
+// Err returns the first error encountered while reading.
+func (s *recordScanner) Err() error {
+	return s.sc.Err()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRsnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	// daily.N for the last 10 days, then weekly.N for the 4 weeks before
+	var stdin bytes.Buffer
+	now := time.Date(2024, 6, 30, 3, 0, 0, 0, time.UTC)
+	mkdir := func(name string, t time.Time) {
+		name = filepath.Join(dir, name)
+		if err := os.Mkdir(name, 0777); err != nil {
+			panic(err)
+		}
+		if err := os.Chtimes(name, t, t); err != nil {
+			panic(err)
+		}
+		stdin.WriteString(name + "\n")
+	}
+	for i := 0; i < 10; i++ {
+		mkdir("daily."+strconv.Itoa(i), now.AddDate(0, 0, -i))
+	}
+	for i := 0; i < 4; i++ {
+		mkdir("weekly."+strconv.Itoa(i), now.AddDate(0, 0, -10-7*i))
+	}
+	stdin.WriteString(filepath.Join(dir, ".sync") + "\n")
+
+	var stdout, stderr bytes.Buffer
+	if status := Main([]string{"snappr", "--rsnapshot", "-q", "7@daily", "2@daily:7"}, &stdin, &stdout, &stderr); status != 0 {
+		t.Fatalf("unexpected exit status %d: %s", status, stderr.String())
+	}
+
+	var act []string
+	for _, line := range strings.Fields(stdout.String()) {
+		act = append(act, filepath.Base(line))
+	}
+	if exp := "daily.7 daily.9 weekly.0 weekly.1 weekly.2 weekly.3"; strings.Join(act, " ") != exp {
+		t.Errorf("expected %q to be pruned, got %q", exp, act)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// semver is a parsed semantic version. Build metadata is ignored.
+type semver struct {
+	Major, Minor, Patch uint64
+	Pre                 []string // prerelease identifiers
+}
+
+// parseSemver parses a semantic version, optionally prefixed with "v".
+func parseSemver(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		v.Pre = strings.Split(pre, ".")
+		for _, x := range v.Pre {
+			if x == "" || strings.TrimLeft(x, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-") != "" {
+				return v, false
+			}
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		if parts[i] == "" || (len(parts[i]) > 1 && parts[i][0] == '0') {
+			return v, false
+		}
+		n, err := strconv.ParseUint(parts[i], 10, 64)
+		if err != nil {
+			return v, false
+		}
+		*p = n
+	}
+	return v, true
+}
+
+// Compare compares the precedence of two versions.
+func (v semver) Compare(o semver) int {
+	if x := cmp.Compare(v.Major, o.Major); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(v.Minor, o.Minor); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(v.Patch, o.Patch); x != 0 {
+		return x
+	}
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1 // a release is newer than its prereleases
+	case len(o.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		a, aerr := strconv.ParseUint(v.Pre[i], 10, 64)
+		b, berr := strconv.ParseUint(o.Pre[i], 10, 64)
+		var x int
+		switch {
+		case aerr == nil && berr == nil:
+			x = cmp.Compare(a, b)
+		case aerr == nil:
+			x = -1 // numeric identifiers are older than alphanumeric ones
+		case berr == nil:
+			x = 1
+		default:
+			x = strings.Compare(v.Pre[i], o.Pre[i])
+		}
+		if x != 0 {
+			return x
+		}
+	}
+	return cmp.Compare(len(v.Pre), len(o.Pre))
+}
+
+// semverLevels are the levels of a semver policy, from coarsest to finest.
+var semverLevels = []string{"major", "minor", "patch"}
+
+// semverPolicy is the number of versions to keep at each level (indexed like
+// semverLevels), where -1 is infinite and 0 is unset. Each major, minor, or
+// patch version is represented by the newest version in it.
+type semverPolicy [3]int
+
+// parseSemverPolicy parses rules in the form N@level, where N@ can be omitted
+// to keep all of them.
+func parseSemverPolicy(rules []string) (semverPolicy, error) {
+	var p semverPolicy
+	for _, rule := range rules {
+		n, level, hasN := strings.Cut(rule, "@")
+		if !hasN {
+			n, level = "-1", n
+		}
+		i := slices.Index(semverLevels, strings.ToLower(level))
+		if i < 0 {
+			return p, fmt.Errorf("rule %q: unknown level %q (must be major, minor, or patch)", rule, level)
+		}
+		vn, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("rule %q: parse count %q: %w", rule, n, err)
+		}
+		if vn == 0 {
+			return p, fmt.Errorf("rule %q: count must not be zero", rule)
+		}
+		if vn > math.MaxInt32 {
+			return p, fmt.Errorf("rule %q: count must be <= %d", rule, math.MaxInt32)
+		}
+		if p[i] != 0 {
+			return p, fmt.Errorf("rule %q: duplicate level", rule)
+		}
+		p[i] = max(int(vn), -1)
+	}
+	return p, nil
+}
+
+// Prune returns the levels requiring each version. Within each major version,
+// the newest minor versions are kept, and within each minor version, the
+// newest patch versions are kept. Identical versions are ordered by their
+// index, so the last one is considered to be the newest.
+func (p semverPolicy) Prune(versions []semver) [][]string {
+	keep := make([][]string, len(versions))
+
+	// sort the versions descending
+	sorted := make([]int, len(versions))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		if x := versions[b].Compare(versions[a]); x != 0 {
+			return x
+		}
+		return cmp.Compare(b, a)
+	})
+
+	for level, count := range p {
+		if count == 0 {
+			continue
+		}
+		seen := map[[2]uint64]int{} // parent -> number kept
+		var last *semver
+		for _, i := range sorted {
+			v := versions[i]
+			// the parent which the count is for, and whether this is the
+			// newest version of a new major/minor version
+			var parent [2]uint64
+			first := last == nil
+			switch level {
+			case 0:
+				first = first || v.Major != last.Major
+			case 1:
+				parent = [2]uint64{v.Major}
+				first = first || v.Major != last.Major || v.Minor != last.Minor
+			case 2:
+				parent = [2]uint64{v.Major, v.Minor}
+				first = true
+			}
+			last = &versions[i]
+			if !first || (count > 0 && seen[parent] >= count) {
+				continue
+			}
+			seen[parent]++
+			keep[i] = append(keep[i], semverLevels[level])
+		}
+	}
+	return keep
+}
+
+func semverMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opt := pflag.NewFlagSet(args[0]+" semver", pflag.ContinueOnError)
+	var (
+		Extract  = opt.StringP("extract", "e", "", "extract the version from each input line using the provided regexp, which must contain up to one capture group")
+		Extended = opt.BoolP("extended-regexp", "E", false, "use full regexp syntax rather than POSIX (see pkg.go.dev/regexp/syntax)")
+		Invert   = opt.BoolP("invert", "v", false, "output the versions to keep instead of the ones to prune")
+		Why      = opt.BoolP("why", "w", false, "explain why each version is being kept to stderr")
+		Help     = opt.BoolP("help", "h", false, "show this help text")
+	)
+
+	if err := opt.Parse(args[1:]); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: %v\n", err)
+		return 2
+	}
+
+	if *Help {
+		fmt.Fprintf(stdout, "usage: %s semver [options] policy...\n", args[0])
+		fmt.Fprintf(stdout, "\noptions:\n%s", opt.FlagUsages())
+		fmt.Fprintf(stdout, "\npolicy: N@level\n")
+		fmt.Fprintf(stdout, "  - level is major, minor, or patch\n")
+		fmt.Fprintf(stdout, "  - N@patch keeps the newest N patch versions of each minor version\n")
+		fmt.Fprintf(stdout, "  - N@minor keeps the newest version of the newest N minor versions of each major version\n")
+		fmt.Fprintf(stdout, "  - N@major keeps the newest version of the newest N major versions\n")
+		fmt.Fprintf(stdout, "  - omit the N@ to keep all of them (e.g., 3@patch 5@minor major)\n")
+		fmt.Fprintf(stdout, "\nnotes:\n")
+		fmt.Fprintf(stdout, "  - reads semantic versions (e.g., v1.2.3-rc.1) from stdin, ordering them by precedence\n")
+		fmt.Fprintf(stdout, "  - prereleases are treated like any other version, and build metadata is ignored\n")
+		return 0
+	}
+
+	if opt.NArg() < 1 {
+		fmt.Fprintf(stderr, "snappr: fatal: at least one policy must be specified (see --help)\n")
+		return 2
+	}
+
+	policy, err := parseSemverPolicy(opt.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: invalid policy: %v\n", err)
+		return 2
+	}
+
+	var extract *regexp.Regexp
+	if *Extract != "" {
+		if extract, err = compileRegexp("extract", *Extract, *Extended, true); err != nil {
+			fmt.Fprintf(stderr, "snappr: fatal: %v\n", err)
+			return 2
+		}
+	}
+
+	var (
+		lines    []string
+		versions []semver
+	)
+	sc := bufio.NewScanner(stdin)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		s := strings.TrimSpace(line)
+		if extract != nil {
+			m := extract.FindStringSubmatch(line)
+			if m == nil {
+				fmt.Fprintf(stderr, "snappr: warning: failed to extract version from %q using regexp %q\n", line, extract.String())
+				continue
+			}
+			s = m[len(m)-1]
+		}
+		v, ok := parseSemver(s)
+		if !ok {
+			fmt.Fprintf(stderr, "snappr: warning: failed to parse version %q\n", s)
+			continue
+		}
+		lines = append(lines, line)
+		versions = append(versions, v)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: failed to read stdin: %v\n", err)
+		return 1
+	}
+
+	for i, why := range policy.Prune(versions) {
+		if len(why) != 0 && *Why {
+			fmt.Fprintf(stderr, "snappr: why: keep %s :: %s\n", lines[i], strings.Join(why, ", "))
+		}
+		if (len(why) != 0) == *Invert {
+			fmt.Fprintln(stdout, lines[i])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSemverCompare(t *testing.T) {
+	// from the semver 2.0.0 spec
+	order := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"v1.0.1+build.1",
+		"1.1.0",
+		"2.0.0",
+		"10.0.0",
+	}
+	for i := range order {
+		a, ok := parseSemver(order[i])
+		if !ok {
+			t.Fatalf("failed to parse %q", order[i])
+		}
+		for j := range order {
+			b, _ := parseSemver(order[j])
+			exp := 0
+			if i < j {
+				exp = -1
+			} else if i > j {
+				exp = 1
+			}
+			if act := a.Compare(b); act != exp {
+				t.Errorf("compare %s to %s: expected %d, got %d", order[i], order[j], exp, act)
+			}
+		}
+	}
+	for _, s := range []string{"", "1", "1.2", "1.2.3.4", "01.2.3", "1.2.x", "1.2.3-", "1.2.3-a..b", "1.2.3-a_b"} {
+		if _, ok := parseSemver(s); ok {
+			t.Errorf("parse %q: expected error", s)
+		}
+	}
+}
+
+func TestSemverPrune(t *testing.T) {
+	policy, err := parseSemverPolicy(strings.Fields("3@patch 1@minor major"))
+	if err != nil {
+		panic(err)
+	}
+	var versions []semver
+	for _, s := range strings.Fields("1.0.0 1.0.1 1.0.2 1.0.3 1.0.4 1.1.0 2.0.0-rc.1 1.1.1 2.0.0") {
+		v, _ := parseSemver(s)
+		versions = append(versions, v)
+	}
+	keep := policy.Prune(versions)
+	for i, exp := range [][]string{
+		nil,
+		nil,
+		{"patch"},
+		{"patch"},
+		{"patch"},
+		{"patch"},
+		{"patch"},
+		{"major", "minor", "patch"},
+		{"major", "minor", "patch"},
+	} {
+		if !slices.Equal(keep[i], exp) {
+			t.Errorf("version %d: expected %v, got %v", i, exp, keep[i])
+		}
+	}
+
+	for _, rules := range []string{"daily", "0@patch", "patch patch", "x@minor"} {
+		if _, err := parseSemverPolicy(strings.Fields(rules)); err == nil {
+			t.Errorf("parse %q: expected error", rules)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pgaskin/snappr"
+	"github.com/spf13/pflag"
+)
+
+// simulateMain implements the simulate command, which prints the number of
+// snapshots a policy retains over time when taking them on a schedule, or how
+// it handles edge cases added to existing snapshots.
+func simulateMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opt := pflag.NewFlagSet(args[0]+" simulate", pflag.ContinueOnError)
+	var (
+		Every  = opt.String("every", "1h", "take a snapshot at this interval (a duration, or a number of days like 1d)")
+		For    = opt.String("for", "365d", "simulate this long (a duration, or a number of days like 365d)")
+		In     = pflag_TimezoneP(opt, "timezone", "z", time.UTC, "timezone to use for the policy and the timeline")
+		Now    = opt.String("now", "", "time to start simulating at, as a unix timestamp or RFC 3339 time (default the current time)")
+		Select = opt.String("select", "first", "which snapshot to keep for each interval (first, last, or closest)")
+		Edge   = opt.Bool("edge-cases", false, "read snapshots (unix timestamps or RFC 3339 times) from stdin, add edge cases (DST transitions, leap days, duplicate timestamps, and future-dated snapshots), and report which of them are kept and how they affect the existing snapshots")
+		Help   = opt.BoolP("help", "h", false, "show this help text")
+	)
+
+	if err := opt.Parse(args[1:]); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: %v\n", err)
+		return 2
+	}
+	if err := opt.Lookup("timezone").Value.(*timezoneFlag).Resolve(); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: invalid --timezone: %v\n", err)
+		return 2
+	}
+
+	if *Help {
+		fmt.Fprintf(stdout, "usage: %s simulate [options] policy...\n", args[0])
+		fmt.Fprintf(stdout, "\noptions:\n%s", opt.FlagUsages())
+		fmt.Fprintf(stdout, "\nnotes:\n")
+		fmt.Fprintf(stdout, "  - takes snapshots every --every after --now, pruning them after each one, and prints the number of snapshots\n")
+		fmt.Fprintf(stdout, "    retained at the end of each day and the number pruned during it, followed by the maximum number retained\n")
+		fmt.Fprintf(stdout, "  - with --edge-cases, the edge cases are the instants around each offset change in --timezone (including the repeated\n")
+		fmt.Fprintf(stdout, "    wall clock time when it goes back), noon on each February 29, a copy of the oldest and newest snapshots, and a\n")
+		fmt.Fprintf(stdout, "    snapshot one day after --now, within the range of the input\n")
+		return 0
+	}
+
+	if opt.NArg() < 1 {
+		fmt.Fprintf(stderr, "snappr: fatal: at least one policy must be specified (see --help)\n")
+		return 2
+	}
+	policy, err := snappr.ParsePolicy(opt.Args()...)
+	if err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: invalid policy: %v\n", err)
+		return 2
+	}
+
+	every, err := parseAge(*Every)
+	if err != nil || every <= 0 {
+		fmt.Fprintf(stderr, "snappr: fatal: --every must be a positive duration\n")
+		return 2
+	}
+	horizon, err := parseAge(*For)
+	if err != nil || horizon <= 0 {
+		fmt.Fprintf(stderr, "snappr: fatal: --for must be a positive duration\n")
+		return 2
+	}
+	if horizon/every > 1<<20 {
+		fmt.Fprintf(stderr, "snappr: fatal: --for is too long for --every (more than %d snapshots)\n", 1<<20)
+		return 2
+	}
+
+	start := time.Now()
+	if *Now != "" {
+		var ok bool
+		if start, ok = parseTimeArg(*Now); !ok {
+			fmt.Fprintf(stderr, "snappr: fatal: --now must be a unix timestamp or RFC 3339 time\n")
+			return 2
+		}
+	}
+
+	var pruneOpt snappr.PruneOptions
+	switch *Select {
+	case "first":
+		pruneOpt.SelectMode = snappr.FirstSnapshot
+	case "last":
+		pruneOpt.SelectMode = snappr.LastSnapshot
+	case "closest":
+		pruneOpt.SelectMode = snappr.ClosestSnapshot
+	default:
+		fmt.Fprintf(stderr, "snappr: fatal: invalid --select %q (must be first, last, or closest)\n", *Select)
+		return 2
+	}
+
+	if *Edge {
+		return simulateEdgeCases(policy, start, *In, &pruneOpt, stdin, stdout, stderr)
+	}
+
+	var most, pruned int
+	steps := snappr.Simulate(snappr.Every(every), policy, start, horizon, *In, &pruneOpt)
+	for i, step := range steps {
+		most = max(most, len(step.Retained))
+		pruned += len(step.Pruned)
+
+		t := step.Time.In(*In)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, *In)
+		if i == len(steps)-1 || !steps[i+1].Time.In(*In).Before(day.AddDate(0, 0, 1)) {
+			fmt.Fprintf(stdout, "%s  %d retained  %d pruned\n", day.Format("Mon 2006 Jan _2"), len(step.Retained), pruned)
+			pruned = 0
+		}
+	}
+	fmt.Fprintf(stdout, "at most %d retained\n", most)
+	return 0
+}
+
+// edgeCase is a snapshot added by simulate --edge-cases.
+type edgeCase struct {
+	Kind string
+	Time time.Time
+}
+
+// simulateEdgeCases prunes the snapshots from stdin with and without edge
+// cases, reporting the reasons for keeping each edge case and the snapshots
+// which are kept or pruned differently because of them.
+func simulateEdgeCases(policy snappr.Policy, now time.Time, loc *time.Location, opt *snappr.PruneOptions, stdin io.Reader, stdout, stderr io.Writer) int {
+	var snapshots []time.Time
+	sc := bufio.NewScanner(stdin)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		t, ok := parseTimeArg(line)
+		if !ok {
+			fmt.Fprintf(stderr, "snappr: fatal: line %d: invalid snapshot %q (must be a unix timestamp or RFC 3339 time)\n", n, line)
+			return 2
+		}
+		snapshots = append(snapshots, t)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: failed to read input: %v\n", err)
+		return 1
+	}
+	if len(snapshots) == 0 {
+		fmt.Fprintf(stderr, "snappr: fatal: --edge-cases requires snapshots on stdin\n")
+		return 2
+	}
+
+	cases := edgeCases(snapshots, now, loc)
+	opt.Now = now
+
+	before := snappr.PruneResult(snapshots, policy, loc, opt)
+	combined := slices.Clone(snapshots)
+	for _, c := range cases {
+		combined = append(combined, c.Time)
+	}
+	after := snappr.PruneResult(combined, policy, loc, opt)
+
+	const layout = "Mon 2006 Jan _2 15:04:05 MST"
+	var kept, changed int
+	for i, c := range cases {
+		if why := after.Reasons[len(snapshots)+i]; len(why) != 0 {
+			fmt.Fprintf(stdout, "%-12s  %s  kept (%s)\n", c.Kind, c.Time.In(loc).Format(layout), strings.Join(periodRules(why), ", "))
+			kept++
+		} else {
+			fmt.Fprintf(stdout, "%-12s  %s  pruned\n", c.Kind, c.Time.In(loc).Format(layout))
+		}
+	}
+	for _, i := range before.SortedIndices() {
+		switch a, b := len(before.Reasons[i]) != 0, len(after.Reasons[i]) != 0; {
+		case a && !b:
+			fmt.Fprintf(stdout, "%-12s  %s  newly pruned\n", "input", snapshots[i].In(loc).Format(layout))
+			changed++
+		case !a && b:
+			fmt.Fprintf(stdout, "%-12s  %s  newly kept\n", "input", snapshots[i].In(loc).Format(layout))
+			changed++
+		}
+	}
+	fmt.Fprintf(stdout, "%d/%d edge cases kept, %d/%d input snapshots changed\n", kept, len(cases), changed, len(snapshots))
+	return 0
+}
+
+// edgeCases generates edge cases within the range of the snapshots, ordered by
+// time.
+func edgeCases(snapshots []time.Time, now time.Time, loc *time.Location) []edgeCase {
+	oldest, newest := slices.MinFunc(snapshots, time.Time.Compare), slices.MaxFunc(snapshots, time.Time.Compare)
+
+	var cases []edgeCase
+	for t := oldest.In(loc); ; {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(newest) {
+			break
+		}
+		_, a := end.Add(-time.Second).Zone()
+		_, b := end.Zone()
+		if a != b {
+			cases = append(cases, edgeCase{"dst-before", end.Add(-time.Second)}, edgeCase{"dst-after", end})
+			if b < a {
+				// the same wall clock time as before the transition
+				cases = append(cases, edgeCase{"dst-repeated", end.Add(time.Duration(a-b)*time.Second - time.Second)})
+			}
+		}
+		t = end
+	}
+	for y := oldest.In(loc).Year(); y <= newest.In(loc).Year(); y++ {
+		if t := time.Date(y, time.February, 29, 12, 0, 0, 0, loc); t.Month() == time.February && !t.Before(oldest) && !t.After(newest) {
+			cases = append(cases, edgeCase{"leap-day", t})
+		}
+	}
+	cases = append(cases,
+		edgeCase{"duplicate", oldest},
+		edgeCase{"duplicate", newest},
+		edgeCase{"future", now.AddDate(0, 0, 1)},
+	)
+	slices.SortStableFunc(cases, func(a, b edgeCase) int {
+		return a.Time.Compare(b.Time)
+	})
+	return cases
+}
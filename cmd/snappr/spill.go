@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// spillThreshold is the total size of input lines to keep in memory before
+// spilling them to disk.
+const spillThreshold = 64 << 20
+
+// lineStore stores input lines, moving them to a temporary file once their
+// total size exceeds Threshold. Only the text of the lines is moved; the
+// offset of each line is still kept in memory. Reading lines in order is
+// buffered, so it is cheaper than random access.
+type lineStore struct {
+	Dir       string // if empty, lines are always kept in memory
+	Threshold int
+
+	mem  []string
+	size int
+
+	f   *os.File
+	w   *bufio.Writer
+	off []int64 // start of each line, followed by the end of the last one
+	err error
+
+	r    *bufio.Reader // reads sequentially from roff, if not nil
+	roff int64
+}
+
+// Append adds a line to the store.
+func (s *lineStore) Append(line string) {
+	if s.f == nil {
+		s.mem = append(s.mem, line)
+		s.size += len(line)
+		if s.Dir == "" || s.err != nil || s.size <= s.Threshold {
+			return
+		}
+		if err := s.spill(); err != nil {
+			s.fail(err)
+		}
+		return
+	}
+	if _, err := s.w.WriteString(line); err != nil {
+		s.fail(err)
+	}
+	s.off = append(s.off, s.off[len(s.off)-1]+int64(len(line)))
+	s.roff = -1 // the reader is limited to the old end of the file
+}
+
+func (s *lineStore) spill() error {
+	f, err := os.CreateTemp(s.Dir, "snappr-spill-*")
+	if err != nil {
+		return err
+	}
+	s.f, s.w, s.off = f, bufio.NewWriter(f), []int64{0}
+	for _, line := range s.mem {
+		if _, err := s.w.WriteString(line); err != nil {
+			return err
+		}
+		s.off = append(s.off, s.off[len(s.off)-1]+int64(len(line)))
+	}
+	s.mem = nil
+	return nil
+}
+
+// Len returns the number of lines in the store.
+func (s *lineStore) Len() int {
+	if s.f == nil {
+		return len(s.mem)
+	}
+	return len(s.off) - 1
+}
+
+// Get returns the line at index i. If it could not be read back from disk, an
+// empty string is returned and the error is available from Err.
+func (s *lineStore) Get(i int) string {
+	if s.f == nil {
+		return s.mem[i]
+	}
+	if s.w.Buffered() != 0 {
+		if err := s.w.Flush(); err != nil {
+			s.fail(err)
+			return ""
+		}
+	}
+	if s.r == nil || s.roff != s.off[i] {
+		sr := io.NewSectionReader(s.f, s.off[i], s.off[len(s.off)-1]-s.off[i])
+		if s.r == nil {
+			s.r = bufio.NewReaderSize(sr, 64<<10)
+		} else {
+			s.r.Reset(sr)
+		}
+		s.roff = s.off[i]
+	}
+	buf := make([]byte, s.off[i+1]-s.off[i])
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		s.fail(err)
+		s.roff = -1
+		return ""
+	}
+	s.roff += int64(len(buf))
+	return string(buf)
+}
+
+func (s *lineStore) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns the first error encountered while spilling lines to disk or
+// reading them back.
+func (s *lineStore) Err() error {
+	return s.err
+}
+
+// Close removes the temporary file, if any.
+func (s *lineStore) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLineStore(t *testing.T) {
+	s := &lineStore{Dir: t.TempDir(), Threshold: 100}
+
+	var exp []string
+	for i := 0; i < 100; i++ {
+		line := "line " + strconv.Itoa(i)
+		if i%10 == 0 {
+			line = ""
+		}
+		s.Append(line)
+		exp = append(exp, line)
+
+		if i%7 == 0 { // interleaved reads
+			if v := s.Get(i / 2); v != exp[i/2] {
+				t.Errorf("line %d: expected %q, got %q", i/2, exp[i/2], v)
+			}
+		}
+	}
+	if s.f == nil {
+		t.Fatalf("expected lines to be spilled")
+	}
+	if n := s.Len(); n != len(exp) {
+		t.Fatalf("expected %d lines, got %d", len(exp), n)
+	}
+	for i, line := range exp {
+		if v := s.Get(i); v != line {
+			t.Errorf("line %d: expected %q, got %q", i, line, v)
+		}
+	}
+	for i := len(exp) - 1; i >= 0; i -= 3 { // out of order
+		if v := s.Get(i); v != exp[i] {
+			t.Errorf("line %d: expected %q, got %q", i, exp[i], v)
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	name := s.f.Name()
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected spill file to be removed")
+	}
+}
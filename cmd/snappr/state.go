@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// runState is the state saved between runs by --state.
+type runState struct {
+	Count   int      `json:"count,omitempty"`   // number of snapshots in the input
+	Pruned  []string `json:"pruned"`            // output lines which were pruned
+	Kept    []string `json:"kept,omitempty"`    // output lines which were kept
+	Missing []string `json:"missing,omitempty"` // periods (prefixed by the group, if any) which were missing snapshots
+
+	Quarantined map[string]int `json:"quarantined,omitempty"` // output lines which were selected for pruning but not output yet, by the number of consecutive runs (for --quarantine)
+}
+
+// loadState reads the state file. If it does not exist, an empty state is
+// returned.
+func loadState(name string) (runState, error) {
+	var st runState
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return st, nil
+		}
+		return st, err
+	}
+	return st, json.Unmarshal(buf, &st)
+}
+
+// saveState atomically replaces the state file.
+func saveState(name string, st runState) error {
+	buf, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".tmp", append(buf, '\n'), 0666); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
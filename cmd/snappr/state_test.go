@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runWithState runs the command with a state file and the specified number of
+// daily snapshots as the input, returning stdout and stderr.
+func runWithState(t *testing.T, state string, days int, args ...string) (string, string) {
+	var stdin, stdout, stderr bytes.Buffer
+	for i := 0; i < days; i++ {
+		stdin.WriteString(strconv.Itoa(1672531200+i*86400) + "\n")
+	}
+	if status := Main(append([]string{"snappr", "--state", state}, args...), &stdin, &stdout, &stderr); status != 0 {
+		t.Fatalf("unexpected exit status %d: %s", status, stderr.String())
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestState(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	run := func(days int, args ...string) string {
+		stdout, _ := runWithState(t, state, days, args...)
+		return stdout
+	}
+
+	if act, exp := run(8, "--only-new", "3@daily"), "1672531200 1672617600 1672704000 1672790400 1672876800"; strings.Join(strings.Fields(act), " ") != exp {
+		t.Errorf("first run: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(9, "--only-new", "3@daily"), "1672963200"; strings.Join(strings.Fields(act), " ") != exp {
+		t.Errorf("second run: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(9, "--only-new", "3@daily"), ""; strings.Join(strings.Fields(act), " ") != exp {
+		t.Errorf("unchanged run: expected %q, got %q", exp, act)
+	}
+	if act := run(9, "3@daily"); len(strings.Fields(act)) != 6 {
+		t.Errorf("run without --only-new: expected all pruned snapshots, got %q", act)
+	}
+}
+
+func TestStateQuarantine(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	run := func(days int, args ...string) string {
+		stdout, _ := runWithState(t, state, days, append([]string{"--quarantine", "2"}, args...)...)
+		return strings.Join(strings.Fields(stdout), " ")
+	}
+
+	if act, exp := run(6, "3@daily"), ""; act != exp {
+		t.Errorf("first run: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(6, "3@daily"), "1672531200 1672617600 1672704000"; act != exp {
+		t.Errorf("second run: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(7, "1@daily"), "1672531200 1672617600 1672704000"; act != exp {
+		t.Errorf("glitched run: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(7, "3@daily"), "1672531200 1672617600 1672704000 1672790400"; act != exp {
+		t.Errorf("run after glitch: expected %q, got %q", exp, act)
+	}
+	if act, exp := run(7, "1@daily"), "1672531200 1672617600 1672704000 1672790400"; act != exp {
+		t.Errorf("second glitched run: expected %q, got %q", exp, act)
+	}
+}
+
+func TestStateExpectWithin(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	run := func(days int) (int, string) {
+		var stdin, stdout, stderr bytes.Buffer
+		for i := 0; i < days; i++ {
+			stdin.WriteString(strconv.Itoa(1672531200+i*86400) + "\n")
+		}
+		status := Main([]string{"snappr", "--state", state, "--expect-within", "20", "3@daily"}, &stdin, &stdout, &stderr)
+		return status, stdout.String()
+	}
+
+	if status, _ := run(10); status != 0 {
+		t.Fatalf("first run: expected exit status 0, got %d", status)
+	}
+	if status, _ := run(8); status != 0 {
+		t.Errorf("run within 20%%: expected exit status 0, got %d", status)
+	}
+	if status, stdout := run(6); status != 3 || stdout != "" {
+		t.Errorf("truncated run: expected exit status 3 without output, got %d with %q", status, stdout)
+	}
+	if status, _ := run(7); status != 0 {
+		t.Errorf("run after truncated run: expected exit status 0, got %d", status)
+	}
+}
+
+func TestDiffState(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	runWithState(t, state, 8, "3@daily", "2@secondly:12h")
+
+	_, act := runWithState(t, state, 10, "--diff-state", "3@daily", "2@secondly:12h", "2@monthly")
+	exp := strings.Join([]string{
+		"snappr: diff: newly kept: 1672531200",
+		"snappr: diff: newly kept: 1673222400",
+		"snappr: diff: newly kept: 1673308800",
+		"snappr: diff: newly pruned: 1672963200",
+		"snappr: diff: newly pruned: 1673049600",
+		"snappr: diff: newly missing: monthly",
+		"",
+	}, "\n")
+	if act != exp {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", exp, act)
+	}
+
+	// --diff-state is a dry run
+	if _, act2 := runWithState(t, state, 10, "--diff-state", "3@daily", "2@secondly:12h", "2@monthly"); act2 != act {
+		t.Errorf("expected state to be unchanged after --diff-state")
+	}
+}
+
+func TestStable(t *testing.T) {
+	for _, tc := range []struct {
+		stable bool
+		exp    string
+	}{
+		{false, "1672531200 1672617600 1672790400 1672963200"},
+		{true, "1672531200 1672617600 1672704000 1672963200"}, // keeps the snapshot kept by the previous policy
+	} {
+		state := filepath.Join(t.TempDir(), "state.json")
+		runWithState(t, state, 6, "3@daily")
+
+		args := []string{"2@daily:2"}
+		if tc.stable {
+			args = append([]string{"--stable"}, args...)
+		}
+		stdout, _ := runWithState(t, state, 6, args...)
+		if act := strings.Join(strings.Fields(stdout), " "); act != tc.exp {
+			t.Errorf("stable=%t: expected %q, got %q", tc.stable, tc.exp, act)
+		}
+	}
+}
+
+func TestStateActionFailed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires false")
+	}
+	dir := t.TempDir()
+	state, failed := filepath.Join(dir, "state.json"), filepath.Join(dir, "failed")
+
+	for i := 0; i < 2; i++ {
+		var stdout, stderr bytes.Buffer
+		status := Main([]string{"snappr", "--state", state, "--only-new", "--action", "exec:false", "--continue-on-error", "--failed-output", failed, "1@last"}, strings.NewReader("1\n2\n3\n"), &stdout, &stderr)
+		if status != 1 {
+			t.Fatalf("run %d: expected exit status 1, got %d", i, status)
+		}
+		if buf, err := os.ReadFile(failed); err != nil {
+			t.Fatalf("run %d: expected failed output: %v", i, err)
+		} else if act, exp := string(buf), "1\n2\n"; act != exp {
+			t.Errorf("run %d: expected failed output %q, got %q", i, exp, act)
+		}
+	}
+}
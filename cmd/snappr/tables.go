@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableSnapshot is a snapshot (Iceberg) or version (Delta) of a table.
+type tableSnapshot struct {
+	ID      string
+	Time    time.Time
+	Current bool // must not be expired
+}
+
+// readIceberg reads the snapshots from an Iceberg table metadata file.
+func readIceberg(r io.Reader) ([]tableSnapshot, error) {
+	var md struct {
+		CurrentSnapshotID *int64 `json:"current-snapshot-id"`
+		Snapshots         []struct {
+			SnapshotID  int64 `json:"snapshot-id"`
+			TimestampMs int64 `json:"timestamp-ms"`
+		} `json:"snapshots"`
+	}
+	if err := json.NewDecoder(r).Decode(&md); err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", err)
+	}
+	snapshots := make([]tableSnapshot, len(md.Snapshots))
+	for i, s := range md.Snapshots {
+		snapshots[i] = tableSnapshot{
+			ID:      strconv.FormatInt(s.SnapshotID, 10),
+			Time:    time.UnixMilli(s.TimestampMs),
+			Current: md.CurrentSnapshotID != nil && *md.CurrentSnapshotID == s.SnapshotID,
+		}
+	}
+	return snapshots, nil
+}
+
+// readDeltaLog reads the versions of a Delta table from the commit files in
+// its _delta_log directory. The commit timestamp is used if present, falling
+// back to the file modification time.
+func readDeltaLog(dir string) ([]tableSnapshot, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []tableSnapshot
+	for _, ent := range ents {
+		name, ok := strings.CutSuffix(ent.Name(), ".json")
+		if !ok || ent.IsDir() {
+			continue
+		}
+		version, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue // not a commit file
+		}
+		t, err := deltaCommitTime(filepath.Join(dir, ent.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read version %d: %w", version, err)
+		}
+		snapshots = append(snapshots, tableSnapshot{
+			ID:   strconv.FormatInt(version, 10),
+			Time: t,
+		})
+	}
+	if len(snapshots) != 0 {
+		slices.SortFunc(snapshots, func(a, b tableSnapshot) int {
+			x, _ := strconv.ParseInt(a.ID, 10, 64)
+			y, _ := strconv.ParseInt(b.ID, 10, 64)
+			return cmp.Compare(x, y)
+		})
+		snapshots[len(snapshots)-1].Current = true
+	}
+	return snapshots, nil
+}
+
+func deltaCommitTime(name string) (time.Time, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var action struct {
+			CommitInfo *struct {
+				Timestamp int64 `json:"timestamp"`
+			} `json:"commitInfo"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &action); err != nil {
+			return time.Time{}, err
+		}
+		if action.CommitInfo != nil && action.CommitInfo.Timestamp != 0 {
+			return time.UnixMilli(action.CommitInfo.Timestamp), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// quoteTableName quotes each dot-separated part of a table name as a Spark SQL
+// identifier (e.g., `db`.`events`), so it can't inject SQL.
+func quoteTableName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telemetry records spans and counters for a single run, and exports them
+// using OTLP/HTTP with JSON encoding. It is configured using the standard
+// OTEL_* environment variables, and a nil *telemetry does nothing.
+type telemetry struct {
+	traces   string // endpoint URLs
+	metrics  string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+	start    time.Time
+	traceID  string
+	spans    []otelSpan
+	counters map[string]int64
+	order    []string // counter names
+}
+
+type otelSpan struct {
+	id, parent string
+	name       string
+	start, end time.Time
+	attrs      map[string]string
+}
+
+// newTelemetry returns a new telemetry recorder if an OTLP endpoint is
+// configured in the environment, or nil otherwise.
+func newTelemetry(getenv func(string) string) (*telemetry, error) {
+	if v := getenv("OTEL_SDK_DISABLED"); strings.EqualFold(v, "true") {
+		return nil, nil
+	}
+	t := &telemetry{
+		headers:  map[string]string{},
+		service:  getenv("OTEL_SERVICE_NAME"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		start:    time.Now(),
+		traceID:  randomID(16),
+		counters: map[string]int64{},
+	}
+	if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+		base = strings.TrimSuffix(base, "/")
+		t.traces, t.metrics = base+"/v1/traces", base+"/v1/metrics"
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		t.traces = v
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"); v != "" {
+		t.metrics = v
+	}
+	if strings.EqualFold(getenv("OTEL_TRACES_EXPORTER"), "none") {
+		t.traces = ""
+	}
+	if strings.EqualFold(getenv("OTEL_METRICS_EXPORTER"), "none") {
+		t.metrics = ""
+	}
+	if t.traces == "" && t.metrics == "" {
+		return nil, nil
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); v != "" && v != "http/json" {
+		return nil, fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_PROTOCOL %q (only http/json is supported)", v)
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_HEADERS"); v != "" {
+		for _, kv := range strings.Split(v, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q", kv)
+			}
+			k, _ = url.QueryUnescape(strings.TrimSpace(k))
+			v, _ = url.QueryUnescape(strings.TrimSpace(v))
+			t.headers[k] = v
+		}
+	}
+	if t.service == "" {
+		t.service = "snappr"
+	}
+	return t, nil
+}
+
+// Span starts a span with the specified parent (or a root span if empty),
+// returning its ID and a function to end it.
+func (t *telemetry) Span(parent, name string, attrs map[string]string) (string, func()) {
+	if t == nil {
+		return "", func() {}
+	}
+	t.spans = append(t.spans, otelSpan{
+		id:     randomID(8),
+		parent: parent,
+		name:   name,
+		start:  time.Now(),
+		attrs:  attrs,
+	})
+	i := len(t.spans) - 1
+	return t.spans[i].id, func() {
+		t.spans[i].end = time.Now()
+	}
+}
+
+// Add adds n to a counter.
+func (t *telemetry) Add(name string, n int64) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.counters[name]; !ok {
+		t.order = append(t.order, name)
+	}
+	t.counters[name] += n
+}
+
+// Flush exports the recorded spans and counters.
+func (t *telemetry) Flush() error {
+	if t == nil {
+		return nil
+	}
+	type kv = map[string]any
+	attrs := func(m map[string]string) []kv {
+		var a []kv
+		for k, v := range m {
+			a = append(a, kv{"key": k, "value": kv{"stringValue": v}})
+		}
+		return a
+	}
+	nanos := func(x time.Time) string {
+		return strconv.FormatInt(x.UnixNano(), 10)
+	}
+	resource := kv{"attributes": attrs(map[string]string{"service.name": t.service})}
+	scope := kv{"name": "github.com/pgaskin/snappr/cmd/snappr"}
+
+	if t.traces != "" && len(t.spans) != 0 {
+		spans := make([]kv, len(t.spans))
+		for i, s := range t.spans {
+			end := s.end
+			if end.IsZero() {
+				end = time.Now()
+			}
+			spans[i] = kv{
+				"traceId":           t.traceID,
+				"spanId":            s.id,
+				"parentSpanId":      s.parent,
+				"name":              s.name,
+				"kind":              1, // internal
+				"startTimeUnixNano": nanos(s.start),
+				"endTimeUnixNano":   nanos(end),
+				"attributes":        attrs(s.attrs),
+			}
+		}
+		if err := t.post(t.traces, kv{"resourceSpans": []kv{{
+			"resource":   resource,
+			"scopeSpans": []kv{{"scope": scope, "spans": spans}},
+		}}}); err != nil {
+			return fmt.Errorf("export traces: %w", err)
+		}
+	}
+
+	if t.metrics != "" && len(t.order) != 0 {
+		now := time.Now()
+		metrics := make([]kv, len(t.order))
+		for i, name := range t.order {
+			metrics[i] = kv{
+				"name": name,
+				"sum": kv{
+					"aggregationTemporality": 1, // delta
+					"isMonotonic":            true,
+					"dataPoints": []kv{{
+						"asInt":             strconv.FormatInt(t.counters[name], 10),
+						"startTimeUnixNano": nanos(t.start),
+						"timeUnixNano":      nanos(now),
+					}},
+				},
+			}
+		}
+		if err := t.post(t.metrics, kv{"resourceMetrics": []kv{{
+			"resource":     resource,
+			"scopeMetrics": []kv{{"scope": scope, "metrics": metrics}},
+		}}}); err != nil {
+			return fmt.Errorf("export metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *telemetry) post(u string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
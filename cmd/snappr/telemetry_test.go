@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelemetry(t *testing.T) {
+	reqs := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		if buf, err := io.ReadAll(r.Body); err != nil {
+			panic(err)
+		} else if err := json.Unmarshal(buf, &v); err != nil {
+			t.Errorf("%s: invalid json: %v", r.URL.Path, err)
+		}
+		if h := r.Header.Get("X-Test"); h != "a b" {
+			t.Errorf("%s: expected header from OTEL_EXPORTER_OTLP_HEADERS, got %q", r.URL.Path, h)
+		}
+		reqs[r.URL.Path] = v
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Test=a%20b")
+
+	var stdout, stderr bytes.Buffer
+	if status := Main([]string{"snappr", "3@daily"}, strings.NewReader("1672531200\n1672617600\n1672704000\n1672790400\nx\n"), &stdout, &stderr); status != 0 {
+		t.Fatalf("unexpected exit status %d: %s", status, stderr.String())
+	}
+
+	traces, metrics := reqs["/v1/traces"], reqs["/v1/metrics"]
+	if traces == nil || metrics == nil {
+		t.Fatalf("expected traces and metrics to be exported, got %v", reqs)
+	}
+
+	var names []string
+	spans := traces["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	for _, span := range spans {
+		names = append(names, span.(map[string]any)["name"].(string))
+	}
+	if act, exp := strings.Join(names, " "), "snappr read prune output"; act != exp {
+		t.Errorf("expected spans %q, got %q", exp, act)
+	}
+
+	counters := map[string]string{}
+	for _, metric := range metrics["resourceMetrics"].([]any)[0].(map[string]any)["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any) {
+		m := metric.(map[string]any)
+		counters[m["name"].(string)] = m["sum"].(map[string]any)["dataPoints"].([]any)[0].(map[string]any)["asInt"].(string)
+	}
+	for name, exp := range map[string]string{
+		"snappr.warnings":         "1",
+		"snappr.snapshots.kept":   "3",
+		"snappr.snapshots.pruned": "1",
+	} {
+		if act := counters[name]; act != exp {
+			t.Errorf("expected %s to be %s, got %q", name, exp, act)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgaskin/snappr"
+	"github.com/spf13/pflag"
+)
+
+// emptyTrash implements the empty-trash command, which deletes files moved to
+// a trash directory by the trash action.
+func emptyTrash(args []string, stdout, stderr io.Writer) int {
+	opt := pflag.NewFlagSet(args[0]+" empty-trash", pflag.ContinueOnError)
+	var (
+		OlderThan = opt.String("older-than", "30d", "only delete files which were moved to the trash at least this long ago (a duration, or a number of days like 30d)")
+		DryRun    = opt.BoolP("dry-run", "n", false, "only print the files which would be deleted")
+		In        = pflag_TimezoneP(opt, "timezone", "z", time.UTC, "timezone to use for the policy")
+		Now       = opt.String("now", "", "reference time for --older-than, as a unix timestamp or RFC 3339 time (default the current time)")
+		Help      = opt.BoolP("help", "h", false, "show this help text")
+	)
+
+	if err := opt.Parse(args[1:]); err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: %v\n", err)
+		return 2
+	}
+
+	if *Help {
+		fmt.Fprintf(stdout, "usage: %s empty-trash [options] dir [policy...]\n", args[0])
+		fmt.Fprintf(stdout, "\noptions:\n%s", opt.FlagUsages())
+		fmt.Fprintf(stdout, "\nnotes:\n")
+		fmt.Fprintf(stdout, "  - deletes files in dir moved there by --action trash:dir, printing their names\n")
+		fmt.Fprintf(stdout, "  - if a policy is specified, files it would keep (by the time they were trashed) are also kept\n")
+		return 0
+	}
+
+	if opt.NArg() < 1 {
+		fmt.Fprintf(stderr, "snappr: fatal: empty-trash requires a directory (see --help)\n")
+		return 2
+	}
+
+	olderThan, err := parseAge(*OlderThan)
+	if err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: invalid --older-than: %v\n", err)
+		return 2
+	}
+
+	now := time.Now()
+	if *Now != "" {
+		if n, err := strconv.ParseInt(*Now, 10, 64); err == nil {
+			now = time.Unix(n, 0)
+		} else if v, err := time.Parse(time.RFC3339, *Now); err == nil {
+			now = v
+		} else {
+			fmt.Fprintf(stderr, "snappr: fatal: --now must be a unix timestamp or RFC 3339 time\n")
+			return 2
+		}
+	}
+
+	var policy snappr.Policy
+	if opt.NArg() > 1 {
+		if policy, err = snappr.ParsePolicy(opt.Args()[1:]...); err != nil {
+			fmt.Fprintf(stderr, "snappr: fatal: invalid policy: %v\n", err)
+			return 2
+		}
+	}
+
+	dir := opt.Arg(0)
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "snappr: fatal: failed to read trash: %v\n", err)
+		return 1
+	}
+
+	var (
+		names []string
+		times []time.Time
+	)
+	for _, ent := range ents {
+		if t, ok := snappr.TrashTime(ent.Name()); ok {
+			names = append(names, filepath.Join(dir, ent.Name()))
+			times = append(times, t)
+		}
+	}
+
+	status := 0
+	result := snappr.PruneResult(times, policy, *In, nil)
+	for i, name := range names {
+		if len(result.Reasons[i]) != 0 || now.Sub(times[i]) < olderThan {
+			continue
+		}
+		if !*DryRun {
+			if err := os.RemoveAll(name); err != nil {
+				fmt.Fprintf(stderr, "snappr: error: failed to delete %q: %v\n", name, err)
+				status = 1
+				continue
+			}
+		}
+		fmt.Fprintln(stdout, name)
+	}
+	return status
+}
+
+// parseAge parses a duration, also accepting a number of days like 30d.
+func parseAge(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		if v, err := strconv.ParseUint(n, 10, 16); err == nil {
+			return time.Duration(v) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
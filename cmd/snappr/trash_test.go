@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	dir, trash := t.TempDir(), filepath.Join(t.TempDir(), "trash")
+
+	var stdin strings.Builder
+	for _, name := range []string{"1672531200", "1672617600", "1672704000"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+			panic(err)
+		}
+		stdin.WriteString(filepath.Join(dir, name) + "\n")
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := Main([]string{"snappr", "-e", `([0-9]+)$`, "--action", "trash:" + trash, "1@last"}, strings.NewReader(stdin.String()), &stdout, &stderr); status != 0 {
+		t.Fatalf("trash: unexpected exit status %d: %s", status, stderr.String())
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) != 1 || ents[0].Name() != "1672704000" {
+		t.Errorf("trash: expected only the newest snapshot to remain, got %v", ents)
+	}
+	ents, _ := os.ReadDir(trash)
+	if len(ents) != 2 {
+		t.Fatalf("trash: expected two files in the trash, got %v", ents)
+	}
+
+	stdout.Reset()
+	if status := Main([]string{"snappr", "empty-trash", "-n", trash}, nil, &stdout, &stderr); status != 0 || stdout.Len() != 0 {
+		t.Errorf("empty-trash: expected recently trashed files to be kept, got status %d and output %q", status, stdout.String())
+	}
+
+	stdout.Reset()
+	if status := Main([]string{"snappr", "empty-trash", "--now", "2100-01-01T00:00:00Z", "--older-than", "1d", trash, "1@last"}, nil, &stdout, &stderr); status != 0 {
+		t.Errorf("empty-trash: unexpected exit status %d: %s", status, stderr.String())
+	}
+	if ents, _ := os.ReadDir(trash); len(ents) != 1 {
+		t.Errorf("empty-trash: expected the file kept by the policy to remain, got %v", ents)
+	}
+	if n := strings.Count(stdout.String(), "\n"); n != 1 {
+		t.Errorf("empty-trash: expected one deleted file to be printed, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,264 @@
+package snappr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CronPeriod is a parsed cron expression for periods with the Cron unit (see
+// ParseCronPeriod).
+type CronPeriod struct {
+	expr                          string // canonical
+	minute, hour, dom, month, dow uint64 // bitsets
+	domStar, dowStar              bool   // unrestricted, for matching days
+}
+
+// maxCronYears is the maximum number of years to search for an occurrence of a
+// cron expression.
+const maxCronYears = 8
+
+// cronFields contains the bounds and names of each cron field.
+var cronFields = [5]struct {
+	name     string
+	min, max int
+	names    []string
+}{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// crons caches parsed cron expressions by canonical expression.
+var crons sync.Map
+
+// ParseCronPeriod parses a standard five-field cron expression (minute, hour,
+// day of month, month, and day of week), where the fields are separated by
+// spaces or underscores (e.g., "0 3 * * SUN" or 0_3_*_*_sun). Each field is *
+// or a comma-separated list of values or ranges (e.g., 1-5), optionally
+// followed by /N to only use every Nth value. Months and days of the week can
+// also be three-letter English names, and Sunday can also be 7. Like cron, if
+// both the day of the month and the day of the week are restricted, days
+// matching either one are used.
+func ParseCronPeriod(expr string) (CronPeriod, error) {
+	var c CronPeriod
+	fields := strings.FieldsFunc(expr, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '_'
+	})
+	if len(fields) != len(cronFields) {
+		return c, fmt.Errorf("cron expression %q must have %d fields", expr, len(cronFields))
+	}
+	for i, field := range fields {
+		field = strings.ToLower(field)
+		fields[i] = field
+		set, err := parseCronField(field, i)
+		if err != nil {
+			return c, fmt.Errorf("cron expression %q: %s: %w", expr, cronFields[i].name, err)
+		}
+		switch i {
+		case 0:
+			c.minute = set
+		case 1:
+			c.hour = set
+		case 2:
+			c.dom, c.domStar = set, field == "*"
+		case 3:
+			c.month = set
+		case 4:
+			if set&(1<<7) != 0 {
+				set = set&^(1<<7) | 1 // sunday
+			}
+			c.dow, c.dowStar = set, field == "*"
+		}
+	}
+	c.expr = strings.Join(fields, " ")
+	if c.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return c, fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return c, nil
+}
+
+// parseCronField parses the i-th field of a cron expression into a bitset.
+func parseCronField(field string, i int) (uint64, error) {
+	f := cronFields[i]
+	value := func(s string) (int, error) {
+		for j, name := range f.names {
+			if s == name {
+				return j + f.min, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < f.min || n > f.max {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return n, nil
+	}
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		item, step, hasStep := strings.Cut(item, "/")
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+		lo, hi := f.min, f.max
+		if item != "*" {
+			a, b, isRange := strings.Cut(item, "-")
+			var err error
+			if lo, err = value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// String returns the canonical form of the cron expression, with lowercase
+// fields separated by spaces.
+func (c CronPeriod) String() string {
+	return c.expr
+}
+
+// Next returns the first occurrence after t, matching the wall clock time in
+// the location of t, or the zero time if there isn't one within a few years.
+func (c CronPeriod) Next(t time.Time) time.Time {
+	n := c.prevCivil(t)
+	if !n.IsZero() {
+		n = c.next(n)
+	}
+	if n.IsZero() {
+		return n
+	}
+	return fromCivil(n, t.Location())
+}
+
+// Prev returns the last occurrence at or before t, matching the wall clock time
+// in the location of t, or the zero time if there isn't one within a few
+// years.
+func (c CronPeriod) Prev(t time.Time) time.Time {
+	n := c.prevCivil(t)
+	if n.IsZero() {
+		return n
+	}
+	return fromCivil(n, t.Location())
+}
+
+// prevCivil returns the civil time (in UTC) of the last occurrence at or
+// before t, taking DST transitions in the location of t into account (i.e., a
+// skipped wall clock time occurs after the transition, and a repeated one
+// occurs the first time).
+func (c CronPeriod) prevCivil(t time.Time) time.Time {
+	o := c.prev(civil(t))
+	for !o.IsZero() && fromCivil(o, t.Location()).After(t) {
+		o = c.prev(o.Add(-time.Minute))
+	}
+	for n := c.next(civil(t)); !n.IsZero() && !fromCivil(n, t.Location()).After(t); n = c.next(n) {
+		o = n
+	}
+	return o
+}
+
+// matchDay checks whether the date of t matches.
+func (c CronPeriod) matchDay(t time.Time) bool {
+	dom, dow := c.dom&(1<<t.Day()) != 0, c.dow&(1<<t.Weekday()) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first occurrence after the civil time t (in UTC).
+func (c CronPeriod) next(t time.Time) time.Time {
+	limit := t.Year() + maxCronYears
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Year() <= limit {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// prev returns the last occurrence at or before the civil time t (in UTC).
+func (c CronPeriod) prev(t time.Time) time.Time {
+	limit := t.Year() - maxCronYears
+	t = t.Truncate(time.Minute)
+	for t.Year() >= limit {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(-time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// civil returns the wall clock time of t as a time in UTC.
+func civil(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, sec := t.Clock()
+	return time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), time.UTC)
+}
+
+// fromCivil returns the time with the wall clock time of the civil time t (in
+// UTC) in loc. If a DST transition skips it, it is shifted later by the length
+// of the gap.
+func fromCivil(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.Date()
+	hour, minute, sec := t.Clock()
+	v := time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), loc)
+	if c := civil(v); c.Before(t) {
+		v = v.Add(t.Sub(c))
+	}
+	return v
+}
+
+// cron returns the parsed cron expression of a valid period with the Cron
+// unit.
+func (p Period) cron() CronPeriod {
+	if c, ok := crons.Load(p.Cron); ok {
+		return c.(CronPeriod)
+	}
+	c, err := ParseCronPeriod(p.Cron)
+	if err != nil {
+		panic("wtf")
+	}
+	crons.Store(p.Cron, c)
+	return c
+}
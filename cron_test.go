@@ -0,0 +1,94 @@
+package snappr
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCronPeriod(t *testing.T) {
+	for expr, exp := range map[string]string{
+		"0 3 * * SUN":        "0 3 * * sun",
+		"0_3_*_*_7":          "0 3 * * 7",
+		"*/15\t9-17 * * 1-5": "*/15 9-17 * * 1-5",
+		"0 0 29 feb *":       "0 0 29 feb *",
+		"0 0 31 2 *":         "",
+		"0 3 * *":            "",
+		"60 3 * * *":         "",
+		"0 3 * * 1-":         "",
+		"0 3 5-1 * *":        "",
+		"0 3 */0 * *":        "",
+		"0 3 * foo *":        "",
+	} {
+		c, err := ParseCronPeriod(expr)
+		if exp == "" {
+			if err == nil {
+				t.Errorf("parse %q: expected error", expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse %q: unexpected error: %v", expr, err)
+		} else if act := c.String(); act != exp {
+			t.Errorf("parse %q: expected %q, got %q", expr, exp, act)
+		}
+	}
+}
+
+func TestCronPeriod(t *testing.T) {
+	est, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		panic(err)
+	}
+	for _, tc := range []struct {
+		expr       string
+		at         time.Time
+		prev, next time.Time
+	}{
+		{"0 3 * * sun", time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 5, 26, 3, 0, 0, 0, time.UTC), time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * sun", time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC), time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC), time.Date(2024, 6, 9, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * mon", time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// skipped by the DST transition, so it occurs at 03:30 EDT
+		{"30 2 * * *", time.Date(2024, 3, 10, 3, 15, 0, 0, est), time.Date(2024, 3, 9, 2, 30, 0, 0, est), time.Date(2024, 3, 10, 3, 30, 0, 0, est)},
+		// repeated by the DST transition, so it only occurs during the first one
+		{"30 1 * * *", time.Date(2024, 11, 3, 6, 15, 0, 0, time.UTC).In(est), time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), time.Date(2024, 11, 4, 1, 30, 0, 0, est)},
+	} {
+		c, err := ParseCronPeriod(tc.expr)
+		if err != nil {
+			panic(err)
+		}
+		if act := c.Prev(tc.at); !act.Equal(tc.prev) {
+			t.Errorf("%q: prev %s: expected %s, got %s", tc.expr, tc.at, tc.prev, act)
+		}
+		if act := c.Next(tc.at); !act.Equal(tc.next) {
+			t.Errorf("%q: next %s: expected %s, got %s", tc.expr, tc.at, tc.next, act)
+		}
+	}
+}
+
+func TestCronPrune(t *testing.T) {
+	policy, err := ParsePolicy("cron:0_3_*_*_sun")
+	if err != nil {
+		panic(err)
+	}
+
+	// every 6 hours from Friday 2024-05-31 00:00
+	var snapshots []time.Time
+	for i := 0; i < 4*16; i++ {
+		snapshots = append(snapshots, time.Date(2024, 5, 31, 6*i, 0, 0, 0, time.UTC))
+	}
+
+	for mode, exp := range map[SelectMode][]int{
+		FirstSnapshot:   {0, 9, 37},  // before the first occurrence, then Sunday 06:00
+		ClosestSnapshot: {8, 36, 63}, // Sunday 00:00 (tied with 06:00), and Saturday 18:00 for the next one
+		LastSnapshot:    {8, 36, 63}, // the last one before the next occurrence
+	} {
+		act := PruneResult(snapshots, policy, time.UTC, &PruneOptions{
+			SelectMode: mode,
+		}).Kept()
+		if !slices.Equal(act, exp) {
+			t.Errorf("mode %d: expected kept %v, got %v", mode, exp, act)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build amd64 && (linux || windows)
+
+package snappr
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+// TestCross runs the tests as a 32-bit binary (which amd64 can run natively on
+// Linux and Windows) and type-checks the packages for the other platform, so
+// int overflow and platform-specific code is caught without a separate CI job.
+func TestCross(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping cross-platform tests in short mode")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("skipping cross-platform tests: %v", err)
+	}
+	run := func(t *testing.T, env []string, args ...string) {
+		cmd := exec.Command(gocmd, args...)
+		cmd.Env = append(os.Environ(), env...)
+		if buf, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%v go %v: %v\n%s", env, args, err, buf)
+		}
+	}
+	t.Run("386", func(t *testing.T) {
+		run(t, []string{"GOARCH=386", "CGO_ENABLED=0"}, "test", "-short", "./...")
+	})
+	goos := "windows"
+	if runtime.GOOS == "windows" {
+		goos = "linux"
+	}
+	t.Run(goos, func(t *testing.T) {
+		run(t, []string{"GOOS=" + goos}, "vet", "./...")
+	})
+}
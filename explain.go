@@ -0,0 +1,242 @@
+package snappr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxExplainSnapshots is the maximum number of snapshots ExplainPolicy will
+// simulate.
+const maxExplainSnapshots = 1 << 20
+
+// Explanation describes the effect of a policy, as returned by ExplainPolicy.
+type Explanation struct {
+	Canonical   string // canonical rules (see Policy.MarshalText)
+	Description string // human-readable description (see Policy.String)
+
+	// Periods describes each period of the policy, in order.
+	Periods []PeriodExplanation
+
+	// Kept is the total number of snapshots kept in the steady state at the
+	// cadence, or -1 if it grows without bound or is unknown (e.g., if the
+	// cadence was not provided).
+	Kept int
+
+	// Warnings contains possible mistakes in the policy, in a human-readable
+	// form. The exact output is subject to change.
+	Warnings []string
+}
+
+// PeriodExplanation describes a single period of a policy.
+type PeriodExplanation struct {
+	Period Period
+	Count  int // from the policy, or -1 if unlimited
+
+	// Kept is the number of snapshots kept for the period in the steady state
+	// at the cadence, or -1 if it grows without bound or is unknown.
+	Kept int
+
+	// Redundant is true if all snapshots kept for the period in the steady
+	// state are also kept for other periods.
+	Redundant bool
+
+	// Alignment describes where the intervals of the period start (e.g.,
+	// "2000-01-01, 2002-01-01, 2004-01-01, ..." for yearly:2), or is empty for
+	// the Last and Within units. The exact output is subject to change.
+	Alignment string
+}
+
+// ExplainPolicy describes the effect of a policy for building user interfaces
+// to edit them. If the cadence (i.e., the time between snapshots) is greater
+// than zero, the steady state is determined by pruning snapshots taken at
+// that cadence in UTC.
+func ExplainPolicy(policy Policy, cadence time.Duration) Explanation {
+	b, _ := policy.MarshalText()
+	e := Explanation{
+		Canonical:   string(b),
+		Description: policy.String(),
+		Kept:        -1,
+	}
+
+	policy.Each(func(period Period, count int) {
+		e.Periods = append(e.Periods, PeriodExplanation{
+			Period:    period,
+			Count:     count,
+			Kept:      -1,
+			Alignment: period.alignment(),
+		})
+		switch period.Unit {
+		case Last:
+			if count < 0 {
+				e.Warnings = append(e.Warnings, fmt.Sprintf("%s keeps every snapshot", period))
+			}
+			if period.Interval != 1 {
+				e.Warnings = append(e.Warnings, fmt.Sprintf("%s keeps different snapshots as new ones are added, so it is only useful for thinning snapshots once", period))
+			}
+		case Within:
+		case Ordinal:
+			e.Warnings = append(e.Warnings, fmt.Sprintf("%s only applies when pruning ordinals", period))
+		default:
+			if cadence > 0 && period.length() < cadence {
+				e.Warnings = append(e.Warnings, fmt.Sprintf("%s is shorter than the cadence, so it keeps one snapshot per %s", period, formatDuration(cadence)))
+			}
+		}
+	})
+	horizon, unbounded := policy.horizon(cadence)
+	if cadence <= 0 {
+		return e
+	}
+	if horizon/cadence >= maxExplainSnapshots {
+		e.Warnings = append(e.Warnings, "the cadence is too short to determine the steady state")
+		return e
+	}
+
+	// simulate enough snapshots for everything but the unlimited periods to
+	// fill up, ending at an arbitrary time
+	end := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := make([]time.Time, horizon/cadence+1)
+	for i := range snapshots {
+		snapshots[i] = end.Add(-time.Duration(len(snapshots)-1-i) * cadence)
+	}
+	r := PruneResult(snapshots, policy, time.UTC, nil)
+
+	if !unbounded {
+		e.Kept = len(r.Kept())
+	}
+	for i := range e.Periods {
+		pe := &e.Periods[i]
+		var kept, unique int
+		for _, why := range r.Reasons {
+			for _, period := range why {
+				if period == pe.Period {
+					if kept++; len(why) == 1 {
+						unique++
+					}
+				}
+			}
+		}
+		if pe.Count >= 0 && pe.Period.Unit != Ordinal {
+			pe.Kept = kept
+		}
+		if pe.Period.Unit != Ordinal && unique == 0 && (pe.Period.Unit != Last || pe.Count >= 0) {
+			pe.Redundant = true
+			e.Warnings = append(e.Warnings, fmt.Sprintf("%s does not keep any snapshots which other rules do not", pe.Period))
+		}
+	}
+	return e
+}
+
+// MaxRetained returns the maximum number of snapshots the policy retains in
+// the steady state when taking a snapshot every schedule and pruning after each
+// one in UTC (e.g., for capacity planning). It returns -1 if the number grows
+// without bound (i.e., a period other than Within has an unlimited count), if
+// the schedule is not positive, or if it is too short to simulate the steady
+// state.
+func (p Policy) MaxRetained(schedule time.Duration) int {
+	if schedule <= 0 {
+		return -1
+	}
+	horizon, unbounded := p.horizon(schedule)
+	if unbounded {
+		return -1
+	}
+
+	// continue for the longest interval after filling up so every alignment
+	// of the intervals is seen
+	var cycle time.Duration
+	p.Each(func(period Period, _ int) {
+		switch period.Unit {
+		case Last, Within, Ordinal:
+		default:
+			cycle = max(cycle, period.length())
+		}
+	})
+	steps := (horizon+cycle)/schedule + 1
+	if steps >= maxExplainSnapshots {
+		return -1
+	}
+
+	var most int
+	for _, step := range Simulate(Every(schedule), p, time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC), horizon+cycle, time.UTC, nil) {
+		most = max(most, len(step.Retained))
+	}
+	return most
+}
+
+// horizon returns the amount of history needed for every period with a finite
+// count to fill up when taking snapshots at the cadence, and whether any period
+// other than Within has an unlimited count.
+func (p Policy) horizon(cadence time.Duration) (horizon time.Duration, unbounded bool) {
+	p.Each(func(period Period, count int) {
+		switch period.Unit {
+		case Last:
+			if count < 0 {
+				unbounded = true
+			} else {
+				horizon = max(horizon, time.Duration(count*period.Interval+1)*cadence)
+			}
+		case Within:
+			horizon = max(horizon, time.Duration(period.Interval)*time.Second+cadence)
+		case Ordinal:
+		default:
+			if count < 0 {
+				unbounded = true
+			} else {
+				horizon = max(horizon, time.Duration(count+1)*max(period.length(), cadence))
+			}
+		}
+	})
+	return
+}
+
+// length returns the approximate length of each interval of the period (other
+// than ones with the Last, Within, or Ordinal unit).
+func (p Period) length() time.Duration {
+	var (
+		i   int64
+		opt = new(PruneOptions)
+	)
+	switch p.Unit {
+	case Workdaily, Cron:
+		i = p.bucket(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC, opt)
+	case Daily:
+		// multi-day intervals may be shorter at the start and end of a year
+		i = p.bucket(time.Date(2000, 7, 1, 0, 0, 0, 0, time.UTC), time.UTC, opt)
+	}
+	return p.bucketStart(p.nextBucket(i, opt), time.UTC, opt).Sub(p.bucketStart(i, time.UTC, opt))
+}
+
+// alignment describes where the intervals of the period start by listing the
+// first few starting at or after 2000-01-01 UTC (or in the zone of the period).
+func (p Period) alignment() string {
+	switch p.Unit {
+	case Last, Within, Pinned:
+		return ""
+	case Ordinal:
+		n, o := int64(p.Interval), int64(p.Offset)
+		return fmt.Sprintf("values %d, %d, %d, ...", o, o+n, o+2*n)
+	}
+	opt := new(PruneOptions)
+	ref := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := p.bucket(ref, time.UTC, opt)
+	if p.bucketStart(i, time.UTC, opt).Before(ref) {
+		i = p.nextBucket(i, opt)
+	}
+	var b strings.Builder
+	for n := 0; n < 3; n++ {
+		t := p.bucketStart(i, time.UTC, opt)
+		switch {
+		case t.Nanosecond() != 0:
+			b.WriteString(t.Format("2006-01-02 15:04:05.000"))
+		case t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0:
+			b.WriteString(t.Format("2006-01-02 15:04:05"))
+		default:
+			b.WriteString(t.Format("2006-01-02"))
+		}
+		b.WriteString(", ")
+		i = p.nextBucket(i, opt)
+	}
+	b.WriteString("...")
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package snappr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainPolicy(t *testing.T) {
+	policy := mustExplainPolicy("3@last", "7@daily", "4@weekly", "2@daily:2", "1@secondly:1h")
+
+	e := ExplainPolicy(policy, time.Hour*6)
+	if b, _ := policy.MarshalText(); e.Canonical != string(b) {
+		t.Errorf("expected canonical %q, got %q", b, e.Canonical)
+	}
+	if e.Description != policy.String() {
+		t.Errorf("expected description %q, got %q", policy.String(), e.Description)
+	}
+	for _, pe := range e.Periods {
+		var exp int
+		switch pe.Period {
+		case Period{Unit: Last, Interval: 1}:
+			exp = 3
+		case Period{Unit: Daily, Interval: 1}:
+			exp = 7
+		case Period{Unit: Daily, Interval: 2}:
+			exp = 2
+		case Period{Unit: Weekly, Interval: 1}:
+			exp = 4
+		case Period{Unit: Secondly, Interval: 3600}:
+			exp = 1
+		default:
+			t.Errorf("unexpected period %s", pe.Period)
+			continue
+		}
+		if pe.Kept != exp {
+			t.Errorf("period %s: expected %d kept, got %d", pe.Period, exp, pe.Kept)
+		}
+		if act := pe.Period.Interval != 1; pe.Redundant != act {
+			t.Errorf("period %s: expected redundant=%t, got %t", pe.Period, act, pe.Redundant)
+		}
+	}
+	if e.Kept <= 7 || e.Kept > 3+7+4+1 {
+		t.Errorf("unexpected total kept %d", e.Kept)
+	}
+	for _, w := range []string{"1h time is shorter", "2 day does not"} {
+		var found bool
+		for _, x := range e.Warnings {
+			if strings.Contains(x, w) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected warning about %q, got %q", w, e.Warnings)
+		}
+	}
+
+	if e := ExplainPolicy(policy, 0); e.Kept != -1 || e.Periods[0].Kept != -1 {
+		t.Errorf("expected unknown steady state without cadence")
+	}
+	if e := ExplainPolicy(mustExplainPolicy("daily", "3@weekly"), time.Hour); e.Kept != -1 || e.Periods[1].Kept != 3 {
+		t.Errorf("expected unbounded steady state with unlimited period, got %d", e.Kept)
+	}
+	if e := ExplainPolicy(mustExplainPolicy("10@yearly"), time.Second); e.Kept != -1 || len(e.Warnings) != 1 {
+		t.Errorf("expected warning for too-short cadence, got %q", e.Warnings)
+	}
+}
+
+func TestExplainAlignment(t *testing.T) {
+	for rule, exp := range map[string]string{
+		"yearly:2":       "2000-01-01, 2002-01-01, 2004-01-01, ...",
+		"yearly:2+1":     "2001-01-01, 2003-01-01, 2005-01-01, ...",
+		"monthly:2":      "2000-02-01, 2000-04-01, 2000-06-01, ...",
+		"daily:7":        "2000-01-01, 2000-01-07, 2000-01-14, ...", // restarts at the start of each year
+		"secondly:6h+1h": "2000-01-01 01:00:00, 2000-01-01 07:00:00, 2000-01-01 13:00:00, ...",
+		"secondly:250ms": "2000-01-01, 2000-01-01 00:00:00.250, 2000-01-01 00:00:00.500, ...",
+		"ordinal:100+10": "values 10, 110, 210, ...",
+		"within:1h":      "",
+		"3@last":         "",
+	} {
+		e := ExplainPolicy(mustExplainPolicy(rule), 0)
+		if act := e.Periods[0].Alignment; act != exp {
+			t.Errorf("%s: expected alignment %q, got %q", rule, exp, act)
+		}
+	}
+}
+
+func mustExplainPolicy(rule ...string) Policy {
+	policy, err := ParsePolicy(rule...)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+func TestPolicyMaxRetained(t *testing.T) {
+	for _, tc := range []struct {
+		policy   string
+		schedule time.Duration
+		exp      int
+	}{
+		{"3@last", time.Hour, 3},
+		{"24@secondly:1h 7@daily", time.Hour, 30},
+		{"24@secondly:1h 7@daily", 15 * time.Minute, 30},
+		{"7@daily", 36 * time.Hour, 7},
+		{"within:24h", time.Hour, 24},
+		{"7@daily 4@weekly", 24 * time.Hour, 10},
+		{"7@daily monthly", 24 * time.Hour, -1},
+		{"last", time.Hour, -1},
+		{"7@daily", 0, -1},
+		{"10@yearly", time.Second, -1},
+	} {
+		if act := mustExplainPolicy(strings.Fields(tc.policy)...).MaxRetained(tc.schedule); act != tc.exp {
+			t.Errorf("%s every %s: expected %d, got %d", tc.policy, tc.schedule, tc.exp, act)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package snappr
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "update testdata/golden.txt")
+
+// TestGolden checks that pruning results are identical to ones computed
+// previously, so they are the same on every architecture and platform (e.g.,
+// GOARCH=386 and GOOS=windows, see TestCross). The inputs are generated with a
+// fixed LCG rather than math/rand so they don't depend on the Go version.
+func TestGolden(t *testing.T) {
+	const name = "testdata/golden.txt"
+
+	var (
+		exp  = map[string]string{}
+		act  []string
+		seed = uint64(0x5eed)
+	)
+	next := func() uint64 {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		return seed >> 33
+	}
+
+	if !*updateGolden {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("open golden file: %v", err)
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if k, v, ok := strings.Cut(sc.Text(), " = "); ok {
+				exp[k] = v
+			}
+		}
+	}
+
+	for _, zone := range []string{"UTC", "America/Toronto", "Australia/Lord_Howe", "Pacific/Chatham", "America/Sao_Paulo"} {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			panic(err)
+		}
+		for _, rules := range []string{
+			"1@last 24@secondly:1h 7@daily 4@daily:7 12@monthly yearly",
+			"3@last 6@secondly:4h+2h~5m 14@daily~1m 6@monthly:2+1 3@yearly:2+1",
+			"log@daily:base=2,min=1,max=3650 log@secondly:min=1m,max=24h",
+			"tiers:15min×8,1h×48,1d×60,1w×104,1m×inf daily/UTC yearly/Pacific/Kiritimati",
+		} {
+			policy, err := ParsePolicy(strings.Fields(rules)...)
+			if err != nil {
+				panic(err)
+			}
+			for _, mode := range []MonthMode{CalendarMonth, FixedMonth} {
+				// ~4 years of snapshots at irregular intervals from a few
+				// seconds to a few days, with some duplicates
+				var snapshots []time.Time
+				for x := int64(1<<30 + next()%(1<<20)); len(snapshots) < 20000; {
+					snapshots = append(snapshots, time.Unix(x, 0))
+					switch next() % 8 {
+					case 0:
+					case 1:
+						x += int64(next() % 60)
+					case 2:
+						x += int64(next() % (3 * 24 * 60 * 60))
+					default:
+						x += int64(next() % (2 * 60 * 60))
+					}
+				}
+				// shuffle them
+				for i := len(snapshots) - 1; i > 0; i-- {
+					j := int(next() % uint64(i+1))
+					snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+				}
+
+				// Result.Hash uses the canonical rules rather than
+				// Period.String, so only the behaviour is pinned
+				k := fmt.Sprintf("%s %q mode=%d", zone, rules, mode)
+				v := PruneResult(snapshots, policy, loc, &PruneOptions{MonthMode: mode}).Hash()
+				if *updateGolden {
+					act = append(act, k+" = "+v)
+				} else if exp[k] != v {
+					t.Errorf("%s: result hash %s does not match golden %s", k, v, exp[k])
+				}
+			}
+		}
+	}
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(strings.Join(act, "\n")+"\n"), 0666); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+	}
+}
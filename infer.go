@@ -0,0 +1,150 @@
+package snappr
+
+import (
+	"slices"
+	"time"
+)
+
+// inferPeriods are the periods considered by InferPolicy, from finest to
+// coarsest.
+var inferPeriods = []Period{
+	{Unit: Secondly, Interval: 60},
+	{Unit: Secondly, Interval: 5 * 60},
+	{Unit: Secondly, Interval: 15 * 60},
+	{Unit: Secondly, Interval: 60 * 60},
+	{Unit: Secondly, Interval: 6 * 60 * 60},
+	{Unit: Daily, Interval: 1},
+	{Unit: Daily, Interval: 7},
+	{Unit: Monthly, Interval: 1},
+	{Unit: Monthly, Interval: 3},
+	{Unit: Yearly, Interval: 1},
+}
+
+// InferPolicy proposes a minimal policy which would keep all of the provided
+// snapshots (e.g., an existing set of snapshots retained by some other means).
+// This is a best-effort heuristic, and the indexes of any snapshots which the
+// policy would not keep are also returned.
+//
+// Each candidate period (from a minute to a year) is used for the newest run
+// of intervals without large gaps in it, if it keeps snapshots which the finer
+// periods did not. Periods are then removed if they are redundant, and the
+// counts are reduced as much as possible.
+func InferPolicy(snapshots []time.Time, loc *time.Location, opt *PruneOptions) (Policy, []int) {
+	if opt == nil {
+		opt = new(PruneOptions)
+	}
+
+	var policy Policy
+	if len(snapshots) == 0 {
+		return policy, nil
+	}
+
+	sorted := make([]int, len(snapshots))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		return snapshots[a].Compare(snapshots[b])
+	})
+
+	covered := make([]bool, len(snapshots))
+	for _, period := range inferPeriods {
+		// the snapshots the period can keep (the first in each interval), newest first
+		var (
+			match   []int
+			buckets []int64
+		)
+		for _, i := range sorted {
+			b := period.bucket(snapshots[i], loc, opt)
+			if len(buckets) == 0 || buckets[len(buckets)-1] != b {
+				match = append(match, i)
+				buckets = append(buckets, b)
+			}
+		}
+		slices.Reverse(match)
+		slices.Reverse(buckets)
+
+		// extend the count until the first gap of more than one interval
+		var (
+			count int
+			extra bool
+		)
+		for j, i := range match {
+			if j != 0 && buckets[j-1]-buckets[j] > 2 {
+				break
+			}
+			if !covered[i] {
+				extra = true
+			}
+			count = j + 1
+		}
+		if extra {
+			policy.Set(period, count)
+			for _, i := range match[:count] {
+				covered[i] = true
+			}
+		}
+	}
+
+	// use a last rule for the newest run of snapshots taken more than once a
+	// minute if the periods don't cover them
+	var (
+		run   int
+		extra bool
+	)
+	for j := len(sorted) - 1; j >= 0; j-- {
+		if j != len(sorted)-1 && snapshots[sorted[j+1]].Sub(snapshots[sorted[j]]) > 2*time.Minute {
+			break
+		}
+		if !covered[sorted[j]] {
+			extra = true
+		}
+		run++
+	}
+	if extra {
+		policy.Set(Period{Unit: Last}, run)
+	}
+
+	unkept := func(policy Policy) []int {
+		var idx []int
+		for i, why := range PruneResult(snapshots, policy, loc, opt).Reasons {
+			if len(why) == 0 {
+				idx = append(idx, i)
+			}
+		}
+		return idx
+	}
+	want := len(unkept(policy))
+	kept := func(policy Policy) bool {
+		return len(unkept(policy)) <= want
+	}
+
+	// remove redundant periods, finest first
+	var periods []Period
+	policy.Each(func(period Period, _ int) {
+		periods = append(periods, period)
+	})
+	for _, period := range periods {
+		count := policy.Get(period)
+		policy.Set(period, 0)
+		if !kept(policy) {
+			policy.Set(period, count)
+		}
+	}
+
+	// reduce the counts
+	policy.Each(func(period Period, count int) {
+		lo, hi := 1, count // hi is always enough
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			policy.Set(period, mid)
+			if kept(policy) {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		policy.Set(period, hi)
+	})
+	return policy, unkept(policy)
+}
@@ -0,0 +1,46 @@
+package snappr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInferPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy   string
+		step     time.Duration
+		inferred string
+	}{
+		{"24@secondly:1h 14@daily 6@monthly", time.Hour, "23@secondly:1h 14@daily 6@monthly"},             // the newest hour is also the newest day
+		{"7@daily 4@daily:7 12@monthly 3@yearly", time.Hour * 6, "6@daily 4@daily:7 12@monthly 3@yearly"}, // the oldest day also starts a 7-day interval
+		{"3@last 12@secondly:5m", time.Second * 20, "3@last 12@secondly:5m"},
+	} {
+		policy, err := ParsePolicy(strings.Fields(tc.policy)...)
+		if err != nil {
+			panic(err)
+		}
+
+		var all []time.Time
+		for x := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC); x.Before(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)); x = x.Add(tc.step) {
+			all = append(all, x)
+		}
+		if len(all) > 100000 {
+			all = all[len(all)-100000:]
+		}
+		var retained []time.Time
+		for i, why := range PruneResult(all, policy, time.UTC, nil).Reasons {
+			if len(why) != 0 {
+				retained = append(retained, all[i])
+			}
+		}
+
+		inferred, unkept := InferPolicy(retained, time.UTC, nil)
+		if len(unkept) != 0 {
+			t.Errorf("%s: inferred policy %s does not keep %d snapshots", tc.policy, inferred, len(unkept))
+		}
+		if act, _ := inferred.MarshalText(); string(act) != tc.inferred {
+			t.Errorf("%s: expected %s, got %s", tc.policy, tc.inferred, act)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package snappr
+
+import "fmt"
+
+// Warning is a possible mistake in a policy, as returned by Policy.Lint.
+type Warning struct {
+	Period  Period // the rule the warning is for
+	Other   Period // the rule shadowing it, if any
+	Message string
+}
+
+// String formats the warning in a human-readable form.
+func (w Warning) String() string {
+	return fmt.Sprintf("rule %s: %s", w.Period, w.Message)
+}
+
+// Lint checks the policy for rules which can never keep any snapshots which
+// another rule does not already keep (i.e., they are shadowed by it), returning
+// a warning for each one in order.
+//
+// A rule is shadowed if the intervals of another rule of the same unit evenly
+// divide its own intervals (e.g., daily:2 by daily:1, or daily:7+1 by daily:1),
+// and that rule covers at least the same amount of time (e.g., 3@daily by
+// 30@daily, but not 30@daily:2 by 30@daily). Rules with the Secondly,
+// Minutely, and Daily units are compared to each other as fixed lengths of
+// time, which is only exact when pruning in UTC (so secondly:86400 is shadowed
+// by daily:1). If both rules shadow each other, only the one with the smaller
+// unit is reported.
+func (p Policy) Lint() []Warning {
+	type rule struct {
+		period Period
+		count  int
+	}
+	var rules []rule
+	p.Each(func(period Period, count int) {
+		rules = append(rules, rule{period, count})
+	})
+
+	var warnings []Warning
+	for i, a := range rules {
+		var (
+			w    *Warning
+			wutc bool
+		)
+		for j, b := range rules {
+			if i == j {
+				continue
+			}
+			utc, ok := shadows(b.period, b.count, a.period, a.count)
+			if !ok || (w != nil && utc) {
+				continue
+			}
+			if _, mutual := shadows(a.period, a.count, b.period, b.count); mutual && a.period.Unit.Compare(b.period.Unit) > 0 {
+				continue // keep the one with the larger unit
+			}
+			w, wutc = &Warning{Period: a.period, Other: b.period}, utc
+			if !utc {
+				break // prefer exact ones
+			}
+		}
+		if w != nil {
+			w.Message = fmt.Sprintf("never keeps any snapshots not also kept by %s", w.Other)
+			if wutc {
+				w.Message += " when pruning in UTC"
+			}
+			warnings = append(warnings, *w)
+		}
+	}
+	return warnings
+}
+
+// shadows checks whether period a with count ca always keeps all snapshots kept
+// by period b with count cb, and whether that's only the case in UTC.
+func shadows(a Period, ca int, b Period, cb int) (utc bool, ok bool) {
+	if a.Unit == Last && a.Interval == 1 && ca < 0 {
+		return false, true // keeps everything
+	}
+	if a.Unit == Within || b.Unit == Within {
+		// only compare to each other since they're relative to the
+		// reference time
+		return false, a.Unit == b.Unit && a.Interval >= b.Interval
+	}
+	if a.Unit == Cron || b.Unit == Cron {
+		return false, false
+	}
+	if a.Slack != b.Slack || a.Zone != b.Zone || a.Anchor != b.Anchor {
+		return false, false
+	}
+
+	ax, ao, aok := a.milliseconds()
+	bx, bo, bok := b.milliseconds()
+	switch {
+	case a.Unit == b.Unit:
+		ax, ao, bx, bo = int64(a.Interval), int64(a.Offset), int64(b.Interval), int64(b.Offset)
+	case aok && bok:
+		utc = a.Unit == Daily || b.Unit == Daily
+		if a.legacyDaily() {
+			return false, false // not aligned to the epoch
+		}
+		if b.legacyDaily() {
+			// b's intervals start at some midnights, so a's intervals must
+			// start at every midnight
+			if day := int64(86400 * 1000); day%ax != 0 || floorMod(ao, ax) != 0 {
+				return false, false
+			}
+			bo = ao
+		}
+	default:
+		return false, false
+	}
+
+	// b's intervals must be made of whole intervals of a
+	if bx%ax != 0 {
+		return false, false
+	}
+	if b.Unit == Last {
+		// every bx/ax-th snapshot kept by a, counted from the newest
+		return utc, ca < 0 || cb >= 0 && int64(ca-1)*ax >= int64(cb-1)*bx
+	}
+	if floorMod(bo-ao, ax) != 0 {
+		return false, false
+	}
+	// every interval kept by b contains at most bx/ax intervals kept by a
+	return utc, ca < 0 || cb >= 0 && int64(ca) >= int64(cb)*(bx/ax)
+}
+
+// milliseconds returns the interval and offset of a Millisecondly, Secondly,
+// Minutely, or Daily period in milliseconds, assuming UTC for Daily.
+func (p Period) milliseconds() (interval, offset int64, ok bool) {
+	var n int64
+	switch p.Unit {
+	case Millisecondly:
+		n = 1
+	case Secondly:
+		n = 1000
+	case Minutely:
+		n = 60 * 1000
+	case Daily:
+		n = 86400 * 1000
+	default:
+		return 0, 0, false
+	}
+	return int64(p.Interval) * n, int64(p.Offset) * n, true
+}
@@ -0,0 +1,51 @@
+package snappr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicyLint(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		exp    []string // period other
+	}{
+		{"3@last 7@daily 4@weekly 12@monthly yearly", nil},
+		{"30@daily 3@daily:2", []string{"daily:2 daily"}},
+		{"30@daily 30@daily:2", nil},
+		{"7@daily 7@secondly:86400", []string{"secondly:24h daily"}},
+		{"daily secondly:86400", []string{"secondly:24h daily"}},
+		{"24@secondly:1h 24@minutely:60", []string{"secondly:1h minutely:60"}},
+		{"48@secondly:1h 2@daily", []string{"daily secondly:1h"}},
+		{"24@secondly:1h 2@daily", nil},
+		{"48@secondly:1h~5m 2@daily", nil},
+		{"10@daily 5@daily:2+1", []string{"daily:2+1 daily"}},
+		{"daily:2 daily:2+1", nil},
+		{"30@daily 3@daily:2 7@secondly:86400", []string{"secondly:24h daily", "daily:2 daily"}},
+		{"10@last 4@last:3", []string{"last:3 last"}},
+		{"10@last 5@last:3", nil},
+		{"last 7@daily yearly", []string{"daily last", "yearly last"}},
+		{"within:24h within:168h", []string{"within:24h within:168h"}},
+		{"within:24h 7@daily", nil},
+		{"100@secondly:7h 2@daily:7", nil}, // multi-day intervals restart at the start of each year
+		{"100@secondly:12h 2@daily:7", []string{"daily:7 secondly:12h"}},
+		{"10@secondly:1 10@millisecondly:1000", []string{"millisecondly:1000 secondly"}},
+		{"10@millisecondly:500 5@secondly:1", []string{"secondly millisecondly:500"}},
+		{"10@millisecondly:300 5@secondly:1", nil},
+	} {
+		policy, err := ParsePolicy(strings.Fields(tc.policy)...)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.policy, err)
+		}
+		var act []string
+		for _, w := range policy.Lint() {
+			a, _ := w.Period.MarshalText()
+			b, _ := w.Other.MarshalText()
+			act = append(act, string(a)+" "+string(b))
+		}
+		if !slices.Equal(act, tc.exp) {
+			t.Errorf("lint %q: expected %q, got %q", tc.policy, tc.exp, act)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package snappr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// presets contains the rules for each preset policy.
+var presets = map[string][]string{
+	// grandfather-father-son rotation
+	"gfs": {"7@daily", "4@weekly", "12@monthly", "yearly"},
+
+	// Apple Time Machine: hourly for the past day, daily for the past month,
+	// and weekly for everything older
+	"timemachine": {"24@secondly:1h", "30@daily", "weekly"},
+
+	// the default retention of zfs-auto-snapshot's frequent, hourly, daily,
+	// weekly, and monthly labels
+	"zfs-auto-snapshot": {"4@secondly:15m", "24@secondly:1h", "31@daily", "8@weekly", "12@monthly"},
+}
+
+// PresetPolicy returns a built-in policy by name. See Presets for the
+// available names.
+func PresetPolicy(name string) (Policy, error) {
+	rules, ok := presets[strings.ToLower(name)]
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown preset %q (must be one of %s)", name, strings.Join(Presets(), ", "))
+	}
+	return ParsePolicy(rules...)
+}
+
+// Presets returns the names of the built-in policies in alphabetical order.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
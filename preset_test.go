@@ -0,0 +1,26 @@
+package snappr
+
+import (
+	"testing"
+)
+
+func TestPresetPolicy(t *testing.T) {
+	for _, name := range Presets() {
+		p, err := PresetPolicy(name)
+		if err != nil {
+			t.Errorf("preset %s: unexpected error: %v", name, err)
+			continue
+		}
+		if len(p.count) == 0 {
+			t.Errorf("preset %s: empty policy", name)
+		}
+	}
+	if p, err := PresetPolicy("GFS"); err != nil {
+		t.Errorf("preset GFS: unexpected error: %v", err)
+	} else if b, _ := p.MarshalText(); string(b) != "7@daily 4@weekly 12@monthly yearly" {
+		t.Errorf("preset GFS: unexpected policy %s", b)
+	}
+	if _, err := PresetPolicy("hanoi"); err == nil {
+		t.Errorf("unknown preset: expected error")
+	}
+}
@@ -0,0 +1,58 @@
+package snappr
+
+import "time"
+
+// Schedule determines when snapshots are taken, for Simulate.
+type Schedule interface {
+	// Next returns the time of the first snapshot after t.
+	Next(t time.Time) time.Time
+}
+
+// Every is a Schedule which takes snapshots at a fixed interval, aligned to
+// multiples of it since the zero time (so intervals evenly dividing a day are
+// aligned to midnight UTC).
+type Every time.Duration
+
+// Next implements Schedule.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// SimulationStep is the result of taking a snapshot and pruning, as returned by
+// Simulate.
+type SimulationStep struct {
+	Time     time.Time   // of the new snapshot
+	Retained []time.Time // after pruning, oldest first (including the new snapshot if it was kept)
+	Pruned   []time.Time // by this step, oldest first
+}
+
+// Simulate takes snapshots on the schedule after start until start+horizon,
+// pruning them after each one (with the new snapshot as the reference time)
+// and discarding the pruned ones, to project the effect of a policy over time.
+// The Now, Clock, Ordinals, Pinned, and Progress fields of opt are ignored.
+func Simulate(schedule Schedule, policy Policy, start time.Time, horizon time.Duration, loc *time.Location, opt *PruneOptions) []SimulationStep {
+	var o PruneOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.Clock, o.Ordinals, o.Pinned, o.Progress = nil, nil, nil, nil
+
+	var (
+		steps    []SimulationStep
+		retained []time.Time
+	)
+	end := start.Add(horizon)
+	for t := schedule.Next(start); t.After(start) && !t.After(end); t = schedule.Next(t) {
+		retained = append(retained, t)
+		o.Now = t
+		r := PruneResult(retained, policy, loc, &o)
+		step := SimulationStep{
+			Time:     t,
+			Retained: r.KeptTimes(retained),
+			Pruned:   r.PrunedTimes(retained),
+		}
+		steps = append(steps, step)
+		retained, start = step.Retained, t
+	}
+	return steps
+}
@@ -0,0 +1,39 @@
+package snappr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	policy, err := ParsePolicy("24@secondly:1h", "7@daily")
+	if err != nil {
+		panic(err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := Simulate(Every(time.Hour), policy, start, 10*24*time.Hour, time.UTC, nil)
+	if len(steps) != 240 {
+		t.Fatalf("expected 240 steps, got %d", len(steps))
+	}
+	if act, exp := steps[0].Time, start.Add(time.Hour); !act.Equal(exp) {
+		t.Errorf("expected first snapshot at %s, got %s", exp, act)
+	}
+	var prev int
+	for i, step := range steps {
+		if len(step.Retained)+len(step.Pruned) != prev+1 {
+			t.Errorf("step %d: expected %d retained and pruned snapshots, got %d+%d", i, prev+1, len(step.Retained), len(step.Pruned))
+		}
+		if n := len(step.Retained); n == 0 || !step.Retained[n-1].Equal(step.Time) {
+			t.Errorf("step %d: expected the new snapshot to be retained", i)
+		}
+		prev = len(step.Retained)
+	}
+	if act := len(steps[len(steps)-1].Retained); act != 30 {
+		t.Errorf("expected 30 retained snapshots at the end, got %d", act)
+	}
+
+	if steps := Simulate(Every(0), policy, start, time.Hour, time.UTC, nil); len(steps) != 0 {
+		t.Errorf("expected no steps for an empty schedule, got %d", len(steps))
+	}
+}
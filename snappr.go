@@ -3,26 +3,62 @@ package snappr
 
 import (
 	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"maps"
+	"math"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Unit represents a precision and unit of measurement.
+//
+// The values of existing units never change, so new units are added at the
+// end. Use Compare to order units by precision.
 type Unit int
 
 const (
-	Last     Unit = iota // snapshot count
-	Secondly             // wallclock seconds
-	Daily                // calendar days
-	Monthly              // calendar months
-	Yearly               // calendar years
+	Last          Unit = iota // snapshot count
+	Secondly                  // wallclock seconds
+	Daily                     // calendar days
+	Monthly                   // calendar months
+	Yearly                    // calendar years
+	Weekly                    // calendar weeks
+	Quarterly                 // calendar quarters
+	Minutely                  // wallclock minutes
+	Within                    // snapshot age in seconds
+	Ordinal                   // arbitrary integers (see PruneOrdinal)
+	Pinned                    // pinned snapshots (see PruneOptions.Pinned)
+	Cron                      // cron expression occurrences (see Period.Cron)
+	Workdaily                 // working days (see PruneOptions.Workdays)
+	Millisecondly             // wallclock milliseconds
 	numUnits
 )
 
+// unitOrder contains the units in the order Compare sorts them in.
+var unitOrder = [numUnits]Unit{
+	Last,
+	Within,
+	Ordinal,
+	Millisecondly,
+	Secondly,
+	Minutely,
+	Daily,
+	Workdaily,
+	Weekly,
+	Monthly,
+	Quarterly,
+	Yearly,
+	Cron,
+	Pinned,
+}
+
 // IsValid checks if the unit is known.
 func (u Unit) IsValid() bool {
 	return u >= 0 && u < numUnits
@@ -37,36 +73,178 @@ func (u Unit) String() string {
 	switch u {
 	case Last:
 		return "last"
+	case Within:
+		return "within"
+	case Ordinal:
+		return "ordinal"
+	case Millisecondly:
+		return "millisecondly"
 	case Secondly:
 		return "secondly"
+	case Minutely:
+		return "minutely"
 	case Daily:
 		return "daily"
+	case Workdaily:
+		return "workdaily"
+	case Weekly:
+		return "weekly"
 	case Monthly:
 		return "monthly"
+	case Quarterly:
+		return "quarterly"
 	case Yearly:
 		return "yearly"
+	case Cron:
+		return "cron"
+	case Pinned:
+		return "pinned"
 	}
 	panic("wtf")
 }
 
-// Compare strictly compares two units.
+// MarshalText encodes the unit as its name, so encoded units do not depend on
+// the order of the constants.
+func (u Unit) MarshalText() ([]byte, error) {
+	if !u.IsValid() {
+		return nil, fmt.Errorf("invalid unit %d", u)
+	}
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText decodes a unit name.
+func (u *Unit) UnmarshalText(b []byte) error {
+	for v := Unit(0); v < numUnits; v++ {
+		if v.String() == string(b) {
+			*u = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown unit %q", b)
+}
+
+// Compare strictly compares two units, ordering them from the most to the
+// least precise (with Last first, and Cron and Pinned last).
 func (u Unit) Compare(other Unit) int {
-	return cmp.Compare(u, other)
+	return cmp.Compare(u.order(), other.order())
+}
+
+// order returns the position of the unit in unitOrder, or the unit itself
+// (which is larger) if it is invalid.
+func (u Unit) order() int {
+	if i := slices.Index(unitOrder[:], u); i != -1 {
+		return i
+	}
+	return int(u)
 }
 
 // Period is a specific time interval for snapshot retention.
+//
+// Intervals are aligned to the Unix epoch for Millisecondly, Secondly, and
+// Minutely, the week containing 1970-01-01 for Weekly, December of year -1 for
+// Monthly (so 2-month intervals start on even months), January of year 0 for
+// Quarterly, and year 0 for Yearly, then shifted forward by Offset units. For
+// example, a 2-year interval starts on even years by default, or on odd years
+// with an offset of 1. Since every time zone currently in use is a whole number
+// of minutes from UTC, Minutely intervals always start on a clock minute.
+//
+// For compatibility with older versions, multi-day Daily intervals without an
+// Anchor are aligned to a day number which only increases every 4 years plus
+// the day of the year, so they restart at the start of each year (e.g., the
+// last interval of a year may be shorter).
+//
+// For Millisecondly, the interval and offset are in milliseconds. ParsePolicy
+// uses it for secondly rules with an interval which isn't a whole number of
+// seconds (e.g., secondly:500ms), for thinning high-frequency snapshots.
+//
+// For Last, the interval is a number of snapshots counted from the newest one
+// (e.g., an interval of 5 keeps every 5th snapshot). Since the snapshots are
+// counted rather than timed, the ones kept shift whenever snapshots are added
+// or removed, so this is mainly useful for thinning an existing history once
+// rather than for repeated pruning.
+//
+// For Within, the interval is a number of seconds, and all snapshots newer than
+// that relative to the reference time (see PruneOptions.Now) are kept.
+//
+// For Ordinal, the interval is a number of values (e.g., ordinal:100 keeps one
+// build per 100 build numbers), aligned to zero.
+//
+// Pinned is only used as a reason for snapshots pinned with
+// PruneOptions.Pinned or PruneOptions.Protected, and cannot be part of a
+// Policy.
+//
+// Slack moves each interval boundary earlier by a fixed duration to tolerate
+// jitter in when snapshots are taken. For example, with a slack of 5 minutes, a
+// daily snapshot scheduled for midnight which was taken at 23:59:58 belongs to
+// the day starting at that midnight rather than the previous one.
+//
+// Zone overrides the location used to split intervals for the period (e.g.,
+// yearly intervals in UTC for compliance, but daily ones in local time).
+//
+// Anchor moves the start of the intervals (before Offset is applied) to match
+// an organizational calendar. For Daily and Weekly, it is a weekday (e.g.,
+// monday, for daily:14 intervals starting on a Monday, or weekly ones
+// regardless of PruneOptions.WeekMode). For Monthly, it is a day of the month
+// from 1-28 (e.g., 15 for months starting on the 15th), and is ignored for
+// FixedMonth. For Quarterly and Yearly, it is a date in the form MM-DD with a
+// day from 1-28 (e.g., 04-01 for fiscal years starting on April 1, regardless
+// of PruneOptions.FiscalYearStart).
+//
+// For Workdaily, the intervals start at the beginning of each working day (see
+// PruneOptions.Workdays) and last until the next one, so snapshots taken on a
+// weekend or holiday belong to the previous working day. The interval must be
+// 1, and the anchor must not be set.
+//
+// For Cron, the intervals start at each occurrence of the cron expression in
+// Cron (see ParseCronPeriod), matched against the wall clock time, so the first
+// snapshot at or after each occurrence is kept (or the closest one with
+// ClosestSnapshot). The interval must be 1, and the offset and anchor must not
+// be set.
 type Period struct {
 	Unit     Unit
-	Interval int // ignored if Unit is Last (normalized to 1), must be > 0
+	Interval int           // 0 is normalized to 1 if Unit is Last, must be > 0 and <= math.MaxInt32
+	Offset   int           // ignored if Unit is Last or Within, normalized to [0, Interval)
+	Slack    time.Duration // ignored if Unit is Last, Within, or Ordinal, must be >= 0
+	Zone     string        // ignored if Unit is Last, Within, or Ordinal, must be empty or a valid IANA time zone name
+	Anchor   string        // ignored if Unit is Last, Within, or Ordinal, must be empty or a valid anchor for the unit
+	Cron     string        // ignored unless Unit is Cron, must be a valid cron expression (normalized to the canonical form)
 }
 
+// maxInt is the largest count, interval, or offset. It is the same on all
+// architectures so policies are parsed and applied identically everywhere.
+const maxInt = math.MaxInt32
+
 // Normalize validates and canonicalizes a period.
 func (p Period) Normalize() (Period, bool) {
 	ok := p.Unit.IsValid()
-	if p.Unit == Last {
+	if (p.Unit == Last && p.Interval == 0) || p.Unit == Pinned {
 		p.Interval = 1
-	} else if p.Interval <= 0 {
+	}
+	if p.Unit != Cron {
+		p.Cron = ""
+	} else if c, err := ParseCronPeriod(p.Cron); err != nil || p.Interval != 1 {
+		ok = false
+	} else {
+		p.Cron = c.String()
+	}
+	if p.Interval <= 0 || p.Interval > maxInt || (p.Unit == Workdaily && p.Interval != 1) {
+		ok = false
+	}
+	if ok {
+		p.Offset = int(floorMod(int64(p.Offset), int64(p.Interval)))
+	}
+	if p.Unit == Last || p.Unit == Within || p.Unit == Pinned {
+		p.Offset, p.Slack, p.Zone, p.Anchor = 0, 0, "", ""
+	} else if p.Unit == Ordinal {
+		p.Slack, p.Zone, p.Anchor = 0, "", ""
+	} else if a, aok := parseAnchor(p.Unit, p.Anchor); !aok {
 		ok = false
+	} else if p.Anchor = a; p.Slack < 0 {
+		ok = false
+	} else if p.Zone != "" {
+		if _, err := loadZone(p.Zone); err != nil {
+			ok = false
+		}
 	}
 	return p, ok
 }
@@ -80,22 +258,64 @@ func (p Period) String() string {
 	}
 	switch p.Unit {
 	case Last:
+		if p.Interval != 1 {
+			return p.Unit.String() + " every " + strconv.Itoa(p.Interval)
+		}
 		return p.Unit.String()
-	case Secondly:
-		s := (time.Second * time.Duration(p.Interval)).String()
-		if v, ok := strings.CutSuffix(s, "m0s"); ok {
-			s = v + "m"
+	case Within:
+		return p.Unit.String() + " " + formatSeconds(p.Interval)
+	case Pinned:
+		return p.Unit.String()
+	case Ordinal:
+		s := strconv.Itoa(p.Interval) + " values"
+		if p.Offset != 0 {
+			s += " offset " + strconv.Itoa(p.Offset)
+		}
+		return s
+	case Cron:
+		s := p.Unit.String() + " " + p.Cron
+		if p.Slack != 0 {
+			s += " slack " + formatDuration(p.Slack)
 		}
-		if v, ok := strings.CutSuffix(s, "h0m"); ok {
-			s = v + "h"
+		if p.Zone != "" {
+			s += " in " + p.Zone
 		}
-		return s + " time"
+		return s
+	case Millisecondly, Secondly:
+		unit := time.Second
+		if p.Unit == Millisecondly {
+			unit = time.Millisecond
+		}
+		s := formatDuration(time.Duration(p.Interval)*unit) + " time"
+		if p.Offset != 0 {
+			s += " offset " + formatDuration(time.Duration(p.Offset)*unit)
+		}
+		if p.Slack != 0 {
+			s += " slack " + formatDuration(p.Slack)
+		}
+		if p.Zone != "" {
+			s += " in " + p.Zone
+		}
+		return s
 	default:
 		k := strings.TrimSuffix(p.Unit.String(), "ly")
-		if k == "dai" {
-			k = "day"
+		if strings.HasSuffix(k, "dai") {
+			k = strings.TrimSuffix(k, "i") + "y"
+		}
+		s := strconv.Itoa(p.Interval) + " " + k
+		if p.Offset != 0 {
+			s += " offset " + strconv.Itoa(p.Offset)
+		}
+		if p.Slack != 0 {
+			s += " slack " + formatDuration(p.Slack)
+		}
+		if p.Zone != "" {
+			s += " in " + p.Zone
+		}
+		if p.Anchor != "" {
+			s += " starting " + p.Anchor
 		}
-		return strconv.Itoa(p.Interval) + " " + k
+		return s
 	}
 }
 
@@ -104,7 +324,156 @@ func (p Period) Compare(other Period) int {
 	if x := p.Unit.Compare(other.Unit); x != 0 {
 		return x
 	}
-	return cmp.Compare(p.Interval, other.Interval)
+	if x := cmp.Compare(p.Interval, other.Interval); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(p.Offset, other.Offset); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(p.Slack, other.Slack); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(p.Zone, other.Zone); x != 0 {
+		return x
+	}
+	if x := cmp.Compare(p.Anchor, other.Anchor); x != 0 {
+		return x
+	}
+	return cmp.Compare(p.Cron, other.Cron)
+}
+
+// formatRule formats a single rule in the form used by Policy.MarshalText.
+func formatRule(period Period, count int) string {
+	b, _ := Policy{map[Period]int{period: count}}.MarshalText()
+	return string(b)
+}
+
+// appendRule appends the period in the form used by Policy.MarshalText.
+func (p Period) appendRule(b []byte) []byte {
+	b = append(b, p.Unit.String()...)
+	if p.Unit == Cron {
+		b = append(b, ':')
+		b = append(b, strings.ReplaceAll(p.Cron, " ", "_")...)
+	}
+	if p.Interval != 1 {
+		b = append(b, ':')
+		if p.Unit == Within || (p.Unit == Secondly && p.Interval >= 60) {
+			b = append(b, formatSeconds(p.Interval)...)
+		} else {
+			b = strconv.AppendInt(b, int64(p.Interval), 10)
+		}
+	}
+	if p.Offset != 0 {
+		b = append(b, '+')
+		if p.Unit == Secondly && p.Offset >= 60 {
+			b = append(b, formatSeconds(p.Offset)...)
+		} else {
+			b = strconv.AppendInt(b, int64(p.Offset), 10)
+		}
+	}
+	if p.Slack != 0 {
+		b = append(b, '~')
+		b = append(b, formatDuration(p.Slack)...)
+	}
+	if p.Zone != "" {
+		b = append(b, '/')
+		b = append(b, p.Zone...)
+	}
+	if p.Anchor != "" {
+		b = append(b, "@anchor="...)
+		b = append(b, p.Anchor...)
+	}
+	return b
+}
+
+// MarshalText encodes the period as a rule without a count (e.g., daily:2+1).
+func (p Period) MarshalText() ([]byte, error) {
+	p, ok := p.Normalize()
+	if !ok {
+		return nil, fmt.Errorf("invalid period")
+	}
+	return p.appendRule(nil), nil
+}
+
+// UnmarshalText decodes a single rule without a count using ParsePeriod.
+func (p *Period) UnmarshalText(b []byte) error {
+	v, err := ParsePeriod(string(b))
+	if err == nil {
+		*p = v
+	}
+	return err
+}
+
+// ParsePeriod parses a single rule without a count (e.g., daily:7 or
+// secondly:1h~5m/UTC) in the form accepted by ParsePolicy, returning the
+// normalized period. It also accepts pinned, for round-tripping reasons.
+func ParsePeriod(s string) (Period, error) {
+	if s == Pinned.String() {
+		return Period{Unit: Pinned, Interval: 1}, nil
+	}
+	if _, tiers := cutPrefixFold(s, "tiers:"); tiers || strings.ContainsAny(strings.Replace(s, "@anchor=", "", 1), "@ \t\n") {
+		return Period{}, fmt.Errorf("period %q must be a single rule without a count", s)
+	}
+	v, err := ParsePolicy(s)
+	if err != nil {
+		return Period{}, err
+	}
+	var (
+		p Period
+		n int
+	)
+	v.Each(func(period Period, _ int) {
+		p = period
+		n++
+	})
+	if n != 1 {
+		return Period{}, fmt.Errorf("period %q must be a single rule", s)
+	}
+	return p, nil
+}
+
+// UnmarshalJSON decodes a period from a JSON string in the form accepted by
+// UnmarshalText, or from a JSON object with the unit, interval (default 1),
+// offset, slack (a Go duration string or nanoseconds), zone, anchor, and cron
+// expression (e.g., {"unit": "daily", "interval": 2, "zone": "UTC"}).
+func (p *Period) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		return p.UnmarshalText([]byte(str))
+	}
+	obj := struct {
+		Unit     *Unit           `json:"unit"`
+		Interval int             `json:"interval"`
+		Offset   int             `json:"offset"`
+		Slack    json.RawMessage `json:"slack"`
+		Zone     string          `json:"zone"`
+		Anchor   string          `json:"anchor"`
+		Cron     string          `json:"cron"`
+	}{Interval: 1}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("period must be a string or an object: %w", err)
+	}
+	if obj.Unit == nil {
+		return fmt.Errorf("period must have a unit")
+	}
+	v := Period{Unit: *obj.Unit, Interval: obj.Interval, Offset: obj.Offset, Zone: obj.Zone, Anchor: obj.Anchor, Cron: obj.Cron}
+	if len(obj.Slack) != 0 {
+		if err := json.Unmarshal(obj.Slack, &str); err == nil {
+			d, err := time.ParseDuration(str)
+			if err != nil {
+				return fmt.Errorf("parse slack: %w", err)
+			}
+			v.Slack = d
+		} else if err := json.Unmarshal(obj.Slack, &v.Slack); err != nil {
+			return fmt.Errorf("parse slack: %w", err)
+		}
+	}
+	v, ok := v.Normalize()
+	if !ok {
+		return fmt.Errorf("invalid period")
+	}
+	*p = v
+	return nil
 }
 
 // Policy defines a retention policy for snapshots.
@@ -117,22 +486,22 @@ type Policy struct {
 // MustSet is like Set, but panics if the period is invalid or has already been
 // used.
 func (p *Policy) MustSet(unit Unit, interval, count int) {
-	if p.Get(Period{unit, interval}) != 0 {
+	if p.Get(Period{Unit: unit, Interval: interval}) != 0 {
 		panic("duplicate period")
 	}
-	if !p.Set(Period{unit, interval}, count) {
+	if !p.Set(Period{Unit: unit, Interval: interval}, count) {
 		panic("invalid period")
 	}
 }
 
 // Set sets the count for a period if it is valid, replacing any existing count.
-// A count of zero removes the period.
+// A count of zero removes the period. Pinned periods cannot be set.
 func (p *Policy) Set(period Period, count int) (ok bool) {
 	if count < 0 {
 		count = -1
 	}
 	period, ok = period.Normalize()
-	if ok {
+	if ok = ok && period.Unit != Pinned; ok {
 		if p.count == nil {
 			p.count = map[Period]int{}
 		}
@@ -145,6 +514,27 @@ func (p *Policy) Set(period Period, count int) (ok bool) {
 	return
 }
 
+// SetWithin keeps all snapshots newer than d relative to the reference time,
+// replacing any existing rule for the same duration. The duration is truncated
+// to seconds, and must be at least one second.
+func (p *Policy) SetWithin(d time.Duration) (ok bool) {
+	if d < time.Second || d/time.Second > maxInt {
+		return false
+	}
+	return p.Set(Period{Unit: Within, Interval: int(d / time.Second)}, -1)
+}
+
+// IsOrdinal checks whether the policy only uses the Last and Ordinal units,
+// which are the only ones meaningful for PruneOrdinal.
+func (p Policy) IsOrdinal() bool {
+	for period := range p.count {
+		if period.Unit != Last && period.Unit != Ordinal {
+			return false
+		}
+	}
+	return true
+}
+
 // Get gets the count for a period if it is set.
 func (p Policy) Get(period Period) (count int) {
 	if p.count != nil {
@@ -198,45 +588,260 @@ func (p Policy) Clone() Policy {
 	return Policy{maps.Clone(p.count)}
 }
 
+// Equal checks whether the policies have the same periods and counts.
+func (p Policy) Equal(other Policy) bool {
+	return maps.Equal(p.count, other.count)
+}
+
+// PolicyChange is a difference between two policies.
+type PolicyChange struct {
+	Period Period
+	Old    int // zero if the period was added
+	New    int // zero if the period was removed
+}
+
+// Added checks whether the period is only in the new policy.
+func (c PolicyChange) Added() bool {
+	return c.Old == 0
+}
+
+// Removed checks whether the period is only in the old policy.
+func (c PolicyChange) Removed() bool {
+	return c.New == 0
+}
+
+// String formats the change in a human-readable form using the rule format
+// (e.g., +7@daily, -yearly, or 4@weekly -> 8@weekly). The exact output is
+// subject to change.
+func (c PolicyChange) String() string {
+	switch {
+	case c.Added():
+		return "+" + formatRule(c.Period, c.New)
+	case c.Removed():
+		return "-" + formatRule(c.Period, c.Old)
+	default:
+		return formatRule(c.Period, c.Old) + " -> " + formatRule(c.Period, c.New)
+	}
+}
+
+// Diff returns the periods which were added, removed, or had their count
+// changed in the other policy, in order. It is empty if the policies are
+// equal.
+func (p Policy) Diff(other Policy) []PolicyChange {
+	var changes []PolicyChange
+	all, _ := p.Merge(other, MergeMax)
+	all.Each(func(period Period, _ int) {
+		if a, b := p.count[period], other.count[period]; a != b {
+			changes = append(changes, PolicyChange{Period: period, Old: a, New: b})
+		}
+	})
+	return changes
+}
+
+// MergeStrategy controls how Policy.Merge combines the counts of periods set in
+// both policies.
+type MergeStrategy int
+
+const (
+	// MergeMax uses the larger count, where unlimited is larger than any
+	// other count.
+	MergeMax MergeStrategy = iota
+
+	// MergeSum adds the counts together, where the sum is unlimited if either
+	// count is.
+	MergeSum
+
+	// MergeError fails if the counts are different.
+	MergeError
+)
+
+// Merge returns a new policy with the periods from both policies, using the
+// strategy to combine the counts of periods set in both. An error is only
+// returned by MergeError.
+func (p Policy) Merge(other Policy, strategy MergeStrategy) (Policy, error) {
+	m := p.Clone()
+	if m.count == nil {
+		m.count = map[Period]int{}
+	}
+	var err error
+	other.Each(func(period Period, count int) {
+		cur, ok := m.count[period]
+		if !ok || err != nil {
+			m.count[period] = count
+			return
+		}
+		switch strategy {
+		case MergeMax:
+			if cur >= 0 && (count < 0 || count > cur) {
+				m.count[period] = count
+			}
+		case MergeSum:
+			if cur >= 0 && count >= 0 {
+				m.count[period] = int(min(int64(cur)+int64(count), maxInt))
+			} else {
+				m.count[period] = -1
+			}
+		case MergeError:
+			if cur != count {
+				err = fmt.Errorf("conflicting rules %s and %s", formatRule(period, cur), formatRule(period, count))
+			}
+		default:
+			panic("invalid merge strategy")
+		}
+	})
+	if err != nil {
+		return Policy{}, err
+	}
+	return m, nil
+}
+
 // ParsePolicy parses a policy from the provided rules.
 //
 // Each rule is in the form N@unit:X, where N is the snapshot count, unit is a
 // unit name, and X is the interval. If N is negative, an infinite number of
 // snapshots is retained. N must not be zero. X must be greater than zero. If N@
 // is omitted, it defaults to -1. If :X is omitted, it defaults to 1. For the
-// "last" unit, X must be 1. For the "secondly" unit, X can also be a duration
-// in the format used by [time.ParseDuration]. Each rule must be unique by the
-// unit:X.
+// "last" unit, X is the number of snapshots between each one kept. For the
+// "secondly" unit, X can also be a duration in the format used by
+// [time.ParseDuration]. For the "within" unit, X is required and is the maximum
+// age, which can also be a duration (e.g., within:24h). X may be followed by +O
+// to shift the start of each interval forward by O units (see [Period]), where
+// O is less than X (and zero for "last" and "within"). Like X, O can be a
+// duration for the "secondly" unit (e.g., secondly:1h+30m for hourly intervals
+// starting at half past the hour). The unit (and X/O, if present) may be
+// followed by ~S, where S is the slack (see [Period]) in the format used by
+// [time.ParseDuration] (e.g., daily~5m). For the "last", "within", and
+// "ordinal" units, S must be zero, and for the "secondly" unit, S must be less
+// than X. The rule may end with @anchor=A to anchor the start of the intervals
+// (see [Period]), where A is a weekday for "daily" and "weekly" (e.g.,
+// daily:14@anchor=monday), a day of the month for "monthly", or a date in the
+// form MM-DD for "quarterly" and "yearly" (e.g., yearly@anchor=04-01). Each
+// rule must be unique by the unit:X+O.
+//
+// Alternatively, N can be "log" to thin snapshots exponentially with age, in
+// which case X is a comma-separated list of key=value parameters (e.g.,
+// log@daily:base=2,min=1,max=365). This expands to a rule for each interval
+// starting at min (default 1) and multiplied by base (default 2) each time,
+// each keeping base snapshots, until the ages up to max (required) are
+// covered. This keeps about one snapshot per base^k units at an age of about
+// base^k units. Like X, min and max can be durations for the "secondly" unit.
+// Log rules do not support offsets.
+//
+// A rule can also be a shorthand for multiple rules in the form
+// tiers:IxN,IxN,..., where I is an interval consisting of a number followed by
+// s, min, h (secondly), d, w (daily, 7 days per week), m or mo (monthly), or y
+// (yearly), and N is the count or "inf". The "x" can also be written as "×".
+// For example, tiers:1h×24,1d×30,1w×52,1m×inf is equivalent to
+// 24@secondly:1h 30@daily 52@daily:7 monthly. Each tier may be followed by /Z
+// to split its intervals in the time zone Z like other rules (e.g.,
+// tiers:1d×30,1m×inf/UTC keeps daily snapshots for the last 30 days using the
+// pruning time zone, and monthly ones using UTC).
 func ParsePolicy(rule ...string) (Policy, error) {
 	var p Policy
 
 	for _, s := range rule {
-		n, u, hasN := strings.Cut(s, "@")
+		if t, ok := cutPrefixFold(s, "tiers:"); ok {
+			if err := parseTiersRule(&p, t); err != nil {
+				return p, fmt.Errorf("rule %q: %w", s, err)
+			}
+			continue
+		}
+
+		v, a, hasA := s, "", false
+		if i := strings.LastIndex(v, "@anchor="); i != -1 {
+			v, a, hasA = v[:i], v[i+len("@anchor="):], true
+		}
+
+		n, u, hasN := strings.Cut(v, "@")
 		if !hasN {
 			n, u = "-1", n
 		}
 
+		var c string
+		if e, ok := cutPrefixFold(u, "cron:"); ok {
+			// the expression may contain slashes, so the zone starts at the
+			// first one followed by a letter
+			i := strings.IndexByte(e, '~')
+			if i == -1 {
+				i = len(e)
+			}
+			for j := 0; j+1 < i; j++ {
+				if r := e[j+1] | 0x20; e[j] == '/' && r >= 'a' && r <= 'z' {
+					i = j
+					break
+				}
+			}
+			c, u = e[:i], u[:len("cron")]+e[i:]
+		}
+
+		u, z, hasZ := strings.Cut(u, "/")
+
+		u, sl, hasSl := strings.Cut(u, "~")
+		if !hasSl {
+			sl = "0s"
+		}
+
 		u, x, hasX := strings.Cut(u, ":")
 		if !hasX {
 			x = "1"
 		}
 
+		x, o, hasO := strings.Cut(x, "+")
+		if !hasO {
+			o = "0"
+		}
+
 		var vu Unit
 		switch strings.ToLower(u) {
 		case "last":
 			vu = Last
+		case "within":
+			vu = Within
+		case "ordinal":
+			vu = Ordinal
+		case "millisecondly":
+			vu = Millisecondly
 		case "secondly":
 			vu = Secondly
+		case "minutely":
+			vu = Minutely
 		case "daily":
 			vu = Daily
+		case "workdaily":
+			vu = Workdaily
+		case "weekly":
+			vu = Weekly
 		case "monthly":
 			vu = Monthly
+		case "quarterly":
+			vu = Quarterly
 		case "yearly":
 			vu = Yearly
+		case "cron":
+			vu = Cron
 		default:
 			return p, fmt.Errorf("rule %q: unknown unit %q", s, u)
 		}
 
+		if strings.EqualFold(n, "log") {
+			if hasO {
+				return p, fmt.Errorf("rule %q: offset is not supported for log rules", s)
+			}
+			if hasA {
+				return p, fmt.Errorf("rule %q: anchor is not supported for log rules", s)
+			}
+			if vu == Cron {
+				return p, fmt.Errorf("rule %q: unit cron is not supported for log rules", s)
+			}
+			if hasZ {
+				return p, fmt.Errorf("rule %q: zone is not supported for log rules", s)
+			}
+			if err := parseLogRule(&p, vu, x, sl); err != nil {
+				return p, fmt.Errorf("rule %q: %w", s, err)
+			}
+			continue
+		}
+
 		vn, err := strconv.ParseInt(n, 10, 64)
 		if err != nil {
 			return p, fmt.Errorf("rule %q: parse count %q: %w", s, n, err)
@@ -244,12 +849,33 @@ func ParsePolicy(rule ...string) (Policy, error) {
 		if vn == 0 {
 			return p, fmt.Errorf("rule %q: count must not be zero", s)
 		}
+		if vn > maxInt {
+			return p, fmt.Errorf("rule %q: count must be <= %d", s, maxInt)
+		}
+
+		if vu == Within && !hasX {
+			return p, fmt.Errorf("rule %q: interval must be specified for unit within", s)
+		}
+
+		// secondly intervals which aren't a whole number of seconds are
+		// converted to millisecondly ones, keeping integer offsets in seconds
+		var subsecond bool
 
 		vx, err := strconv.ParseInt(x, 10, 64)
-		if vu == Secondly && err != nil {
+		if (vu == Millisecondly || vu == Secondly || vu == Within) && err != nil {
 			var tmp time.Duration
 			tmp, err = time.ParseDuration(x)
-			vx = int64(tmp / time.Second)
+			if vu == Secondly && tmp%time.Second != 0 {
+				vu, subsecond = Millisecondly, true
+			}
+			if vu == Millisecondly {
+				if err == nil && tmp%time.Millisecond != 0 {
+					return p, fmt.Errorf("rule %q: interval must be a whole number of milliseconds", s)
+				}
+				vx = int64(tmp / time.Millisecond)
+			} else {
+				vx = int64(tmp / time.Second)
+			}
 		}
 		if err != nil {
 			return p, fmt.Errorf("rule %q: parse interval %q: %w", s, x, err)
@@ -257,82 +883,898 @@ func ParsePolicy(rule ...string) (Policy, error) {
 		if vx < 1 {
 			return p, fmt.Errorf("rule %q: interval must be > 0", s)
 		}
-		if vu == Last && vx != 1 {
-			return p, fmt.Errorf("rule %q: interval must be 1 for unit last", s)
+		if vx > maxInt {
+			return p, fmt.Errorf("rule %q: interval must be <= %d", s, maxInt)
+		}
+
+		vo, err := strconv.ParseInt(o, 10, 64)
+		if subsecond && err == nil {
+			vo *= 1000
+		}
+		if (vu == Millisecondly || vu == Secondly) && err != nil {
+			var tmp time.Duration
+			tmp, err = time.ParseDuration(o)
+			if vu == Millisecondly {
+				vo = int64(tmp / time.Millisecond)
+			} else {
+				vo = int64(tmp / time.Second)
+			}
+		}
+		if err != nil {
+			return p, fmt.Errorf("rule %q: parse offset %q: %w", s, o, err)
+		}
+		if vo < 0 || vo >= vx {
+			return p, fmt.Errorf("rule %q: offset must be >= 0 and < interval", s)
+		}
+		if vu == Last && vo != 0 {
+			return p, fmt.Errorf("rule %q: offset must be zero for unit last", s)
+		}
+		if vu == Within && vo != 0 {
+			return p, fmt.Errorf("rule %q: offset must be zero for unit within", s)
+		}
+		if vu == Workdaily && vx != 1 {
+			return p, fmt.Errorf("rule %q: interval must be 1 for unit workdaily", s)
+		}
+
+		vs, err := time.ParseDuration(sl)
+		if err != nil {
+			return p, fmt.Errorf("rule %q: parse slack %q: %w", s, sl, err)
+		}
+		if vs < 0 {
+			return p, fmt.Errorf("rule %q: slack must be >= 0", s)
+		}
+		if vu == Last && vs != 0 {
+			return p, fmt.Errorf("rule %q: slack must be zero for unit last", s)
+		}
+		if (vu == Within || vu == Ordinal) && vs != 0 {
+			return p, fmt.Errorf("rule %q: slack must be zero for unit %s", s, vu)
+		}
+		if vu == Millisecondly && vs >= time.Duration(vx)*time.Millisecond {
+			return p, fmt.Errorf("rule %q: slack must be < interval", s)
+		}
+		if vu == Secondly && vs >= time.Duration(vx)*time.Second {
+			return p, fmt.Errorf("rule %q: slack must be < interval", s)
+		}
+		if vu == Minutely && vs >= time.Duration(vx)*time.Minute {
+			return p, fmt.Errorf("rule %q: slack must be < interval", s)
+		}
+
+		if hasZ {
+			if vu == Last || vu == Within || vu == Ordinal {
+				return p, fmt.Errorf("rule %q: zone must not be set for unit %s", s, vu)
+			}
+			if _, err := loadZone(z); err != nil || z == "" {
+				return p, fmt.Errorf("rule %q: invalid zone %q", s, z)
+			}
+		}
+
+		var vc string
+		if vu == Cron {
+			if hasX {
+				return p, fmt.Errorf("rule %q: interval must not be set for unit cron", s)
+			}
+			cp, err := ParseCronPeriod(c)
+			if err != nil {
+				return p, fmt.Errorf("rule %q: %w", s, err)
+			}
+			vc = cp.String()
+		}
+
+		var va string
+		if hasA {
+			var ok bool
+			if va, ok = parseAnchor(vu, a); !ok || va == "" {
+				return p, fmt.Errorf("rule %q: invalid anchor %q for unit %s", s, a, vu)
+			}
 		}
-		if p.Get(Period{Unit: vu, Interval: int(vx)}) != 0 {
-			return p, fmt.Errorf("rule %q: duplicate %s:%d", s, u, vx)
+
+		period := Period{Unit: vu, Interval: int(vx), Offset: int(vo), Slack: vs, Zone: z, Anchor: va, Cron: vc}
+		if p.Get(period) != 0 {
+			return p, fmt.Errorf("rule %q: duplicate period", s)
 		}
-		if !p.Set(Period{Unit: vu, Interval: int(vx)}, int(vn)) {
-			return p, fmt.Errorf("rule %q: invalid period %s:%d", s, u, vx)
+		if !p.Set(period, int(vn)) {
+			return p, fmt.Errorf("rule %q: invalid period", s)
 		}
 	}
 
 	return p, nil
 }
 
-// UnmarshalText parses the provided text into p, replacing the existing
-// policy. It splits the text by whitespace and calls ParsePolicy.
-func (p *Policy) UnmarshalText(b []byte) error {
-	v, err := ParsePolicy(strings.Fields(string(b))...)
-	if err == nil {
-		*p = v
-	}
-	return err
-}
+// parseTiersRule adds the periods for a comma-separated list of IxN tiers to p.
+func parseTiersRule(p *Policy, tiers string) error {
+	for _, tier := range strings.Split(tiers, ",") {
+		x, n, ok := strings.Cut(tier, "×")
+		if !ok {
+			x, n, ok = strings.Cut(tier, "x")
+		}
+		if !ok {
+			return fmt.Errorf("tier %q: expected IxN", tier)
+		}
 
-// MarshalText encodes the policy into a form usable by UnmarshalText. The
-// output is the canonical form of the rules (i.e., all equivalent policies will
-// result in the same output).
-func (p Policy) MarshalText() ([]byte, error) {
-	var b []byte
-	p.Each(func(period Period, count int) {
-		if b != nil {
-			b = append(b, ' ')
+		i := strings.IndexFunc(x, func(r rune) bool {
+			return r < '0' || r > '9'
+		})
+		if i <= 0 {
+			return fmt.Errorf("tier %q: expected interval like 1h, 1d, 1w, 1m, or 1y", tier)
 		}
-		if count > 0 {
-			b = strconv.AppendInt(b, int64(count), 10)
-			b = append(b, '@')
+		vx, err := strconv.ParseInt(x[:i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("tier %q: parse interval: %w", tier, err)
 		}
-		b = append(b, period.Unit.String()...)
-		if period.Interval != 1 {
-			b = append(b, ':')
-			if period.Unit == Secondly && period.Interval >= 60 {
-				s := (time.Second * time.Duration(period.Interval)).String()
-				if v, ok := strings.CutSuffix(s, "m0s"); ok {
-					s = v + "m"
-				}
-				if v, ok := strings.CutSuffix(s, "h0m"); ok {
-					s = v + "h"
-				}
-				b = append(b, s...)
-			} else {
-				b = strconv.AppendInt(b, int64(period.Interval), 10)
+		if vx > maxInt {
+			return fmt.Errorf("tier %q: interval too large", tier) // before multiplying it
+		}
+		var period Period
+		switch x[i:] {
+		case "s":
+			period.Unit = Secondly
+		case "min":
+			period.Unit, vx = Secondly, vx*60
+		case "h":
+			period.Unit, vx = Secondly, vx*60*60
+		case "d":
+			period.Unit = Daily
+		case "w":
+			period.Unit, vx = Daily, vx*7
+		case "m", "mo":
+			period.Unit = Monthly
+		case "y":
+			period.Unit = Yearly
+		default:
+			return fmt.Errorf("tier %q: unknown interval unit %q", tier, x[i:])
+		}
+		if vx > maxInt {
+			return fmt.Errorf("tier %q: interval too large", tier)
+		}
+		period.Interval = int(vx)
+
+		if c, z, hasZ := strings.Cut(n, "/"); hasZ {
+			if _, err := loadZone(z); err != nil || z == "" {
+				return fmt.Errorf("tier %q: invalid zone %q", tier, z)
 			}
+			n, period.Zone = c, z
 		}
-	})
-	return b, nil
+
+		var vn int64
+		if strings.EqualFold(n, "inf") {
+			vn = -1
+		} else if vn, err = strconv.ParseInt(n, 10, 64); err != nil {
+			return fmt.Errorf("tier %q: parse count: %w", tier, err)
+		} else if vn < 1 {
+			return fmt.Errorf("tier %q: count must be > 0 or inf", tier)
+		} else if vn > maxInt {
+			return fmt.Errorf("tier %q: count must be <= %d", tier, maxInt)
+		}
+
+		if p.Get(period) != 0 {
+			return fmt.Errorf("tier %q: duplicate period", tier)
+		}
+		if !p.Set(period, int(vn)) {
+			return fmt.Errorf("tier %q: invalid period", tier)
+		}
+	}
+	return nil
 }
 
-// Prune prunes the provided list of snapshots, returning a matching slice of
-// periods requiring that snapshot, and the remaining number of snapshots
-// required to fulfill the original policy.
-//
-// All snapshots are placed in the provided timezone, and the monotonic time
-// component is removed. The timezone affects the exact point at which calendar
-// days/months/years are split. Beware of duplicate timestamps at DST
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// parseLogRule adds the periods for a log rule with the specified unit and
+// comma-separated key=value parameters to p.
+func parseLogRule(p *Policy, unit Unit, params, slack string) error {
+	if unit == Last || unit == Within || unit == Workdaily {
+		return fmt.Errorf("log rules are not supported for unit %s", unit)
+	}
+	base, lo, hi := int64(2), int64(1), int64(0)
+	for _, kv := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("parse log parameter %q: expected key=value", kv)
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if (unit == Millisecondly || unit == Secondly) && err != nil && k != "base" {
+			var tmp time.Duration
+			tmp, err = time.ParseDuration(v)
+			if unit == Millisecondly {
+				n = int64(tmp / time.Millisecond)
+			} else {
+				n = int64(tmp / time.Second)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("parse log parameter %q: %w", kv, err)
+		}
+		switch k {
+		case "base":
+			base = n
+		case "min":
+			lo = n
+		case "max":
+			hi = n
+		default:
+			return fmt.Errorf("unknown log parameter %q", k)
+		}
+	}
+	if base < 2 {
+		return fmt.Errorf("log base must be >= 2")
+	}
+	if lo < 1 {
+		return fmt.Errorf("log min must be > 0")
+	}
+	if hi < lo {
+		return fmt.Errorf("log max must be set and >= min")
+	}
+	if hi > maxInt || base > maxInt {
+		return fmt.Errorf("log parameters must be <= %d", maxInt)
+	}
+
+	vs, err := time.ParseDuration(slack)
+	if err != nil {
+		return fmt.Errorf("parse slack %q: %w", slack, err)
+	}
+	if vs < 0 {
+		return fmt.Errorf("slack must be >= 0")
+	}
+	if unit == Millisecondly && vs >= time.Duration(lo)*time.Millisecond {
+		return fmt.Errorf("slack must be < min")
+	}
+	if unit == Secondly && vs >= time.Duration(lo)*time.Second {
+		return fmt.Errorf("slack must be < min")
+	}
+	if unit == Minutely && vs >= time.Duration(lo)*time.Minute {
+		return fmt.Errorf("slack must be < min")
+	}
+
+	// keep base snapshots at each interval, so each tier covers base times
+	// the age of the previous one, until the ages up to max are covered
+	for x := lo; ; x *= base {
+		last := x >= (hi+base-1)/base // x*base >= hi, without overflowing
+		count := base
+		if last {
+			count = (hi + x - 1) / x
+		}
+		period := Period{Unit: unit, Interval: int(x), Slack: vs}
+		if p.Get(period) != 0 {
+			return fmt.Errorf("duplicate period %s", period)
+		}
+		if !p.Set(period, int(count)) {
+			return fmt.Errorf("invalid period %s", period)
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// UnmarshalText parses the provided text into p, replacing the existing
+// policy. It splits the text by whitespace and calls ParsePolicy.
+func (p *Policy) UnmarshalText(b []byte) error {
+	v, err := ParsePolicy(strings.Fields(string(b))...)
+	if err == nil {
+		*p = v
+	}
+	return err
+}
+
+// MarshalText encodes the policy into a form usable by UnmarshalText. The
+// output is the canonical form of the rules (i.e., all equivalent policies will
+// result in the same output).
+func (p Policy) MarshalText() ([]byte, error) {
+	var b []byte
+	p.Each(func(period Period, count int) {
+		if b != nil {
+			b = append(b, ' ')
+		}
+		if count > 0 {
+			b = strconv.AppendInt(b, int64(count), 10)
+			b = append(b, '@')
+		}
+		b = period.appendRule(b)
+	})
+	return b, nil
+}
+
+// MarshalJSON encodes the policy as a JSON string containing the output of
+// MarshalText.
+func (p Policy) MarshalJSON() ([]byte, error) {
+	b, err := p.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(b))
+}
+
+// UnmarshalJSON decodes a policy from a JSON string in the form accepted by
+// UnmarshalText, or from a JSON object mapping rules without counts to counts
+// (e.g., {"last": 3, "daily": 7, "yearly": -1}), replacing the existing policy.
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		return p.UnmarshalText([]byte(str))
+	}
+	var obj map[string]int
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("policy must be a string or an object: %w", err)
+	}
+	var v Policy
+	for rule, count := range obj {
+		period, err := ParsePeriod(rule)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("rule %q: count must not be zero", rule)
+		}
+		if v.Get(period) != 0 {
+			return fmt.Errorf("rule %q: duplicate period %s", rule, period)
+		}
+		if !v.Set(period, count) {
+			return fmt.Errorf("rule %q: invalid period", rule)
+		}
+	}
+	*p = v
+	return nil
+}
+
+// Window is a concrete interval of a period.
+type Window struct {
+	Period Period
+	Start  time.Time // inclusive
+	End    time.Time // exclusive
+}
+
+// Windows returns the intervals of each period in the policy (other than ones
+// with the Last, Within, or Ordinal unit) which overlap the range [from, to),
+// as used by Prune. The windows are ordered by period, then by start time.
+// Note that this may return a very large number of windows for short intervals
+// over long ranges.
+func (p Policy) Windows(from, to time.Time, loc *time.Location) []Window {
+	var (
+		ws  []Window
+		opt = new(PruneOptions)
+	)
+	p.Each(func(period Period, _ int) {
+		if period.Unit == Last || period.Unit == Within || period.Unit == Ordinal {
+			return
+		}
+		i := period.bucket(from, loc, opt)
+		start := period.bucketStart(i, loc, opt)
+		for start.Before(to) {
+			next := period.nextBucket(i, opt)
+			end := period.bucketStart(next, loc, opt)
+			ws = append(ws, Window{
+				Period: period,
+				Start:  start,
+				End:    end,
+			})
+			i, start = next, end
+		}
+	})
+	return ws
+}
+
+// Result contains the result of pruning a list of snapshots.
+type Result struct {
+	// Reasons contains the periods requiring each snapshot, in the same order
+	// as the input snapshots. If a snapshot has no reasons, it can be pruned.
+	Reasons [][]Period
+
+	// Need contains the remaining number of snapshots required to fulfill the
+	// original policy.
+	Need Need
+
+	sorted []int // snapshot indexes, oldest first
+	rank   []int // by snapshot index, newest first
+}
+
+// Need contains the number of snapshots still missing for each period of a
+// policy after pruning.
+type Need struct {
+	count map[Period]int // same keys as the policy
+}
+
+// Missing returns the number of additional snapshots required to fulfill the
+// count for the period, or -1 if its count is unlimited. It is zero if the
+// period is satisfied or is not part of the policy.
+func (n Need) Missing(period Period) int {
+	return Policy(n).Get(period)
+}
+
+// Satisfied checks whether all periods have as many snapshots as their count.
+// Periods with unlimited counts are always satisfied.
+func (n Need) Satisfied() bool {
+	for _, count := range n.count {
+		if count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Each loops over all periods of the policy in order, including satisfied
+// ones.
+func (n Need) Each(fn func(period Period, missing int)) {
+	Policy(n).Each(fn)
+}
+
+// Policy returns the need as a policy where the counts are the number of
+// missing snapshots, as returned by Prune.
+func (n Need) Policy() Policy {
+	return Policy(n).Clone()
+}
+
+// String formats the need in a human-readable form. The exact output is
+// subject to change.
+func (n Need) String() string {
+	return Policy(n).String()
+}
+
+// SortedIndices returns the indexes of the input snapshots in the order used
+// while pruning (oldest first, by real time).
+func (r Result) SortedIndices() []int {
+	return slices.Clone(r.sorted)
+}
+
+// Rank returns the recency rank of the snapshot at index i of the input, where
+// the newest snapshot has rank 0. It is only available for results returned by
+// PruneResult, and is -1 for other results (e.g., ones constructed directly) or
+// if i is out of range.
+func (r Result) Rank(i int) int {
+	if i < 0 || i >= len(r.rank) {
+		return -1
+	}
+	return r.rank[i]
+}
+
+// Hash returns a stable digest of the decisions (e.g., to cheaply verify that
+// multiple replicas would prune the same snapshots before acting). It is the
+// hex-encoded SHA-256 hash of a line for each input snapshot in input order,
+// containing the rules keeping it (in the form used by Policy.MarshalText,
+// sorted by Period.Compare, and separated by commas) or nothing if it can be
+// pruned, followed by a line containing "need " and the remaining count and
+// rule for each period with a finite count (e.g., "need 0@daily"), in the
+// order of Policy.Each.
+func (r Result) Hash() string {
+	h := sha256.New()
+	var b []byte
+	for _, why := range r.Reasons {
+		b = b[:0]
+		why = slices.Clone(why)
+		slices.SortFunc(why, Period.Compare)
+		for i, period := range why {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			b = period.appendRule(b)
+		}
+		b = append(b, '\n')
+		h.Write(b)
+	}
+	r.Need.Policy().Each(func(period Period, count int) {
+		if count >= 0 {
+			fmt.Fprintf(h, "need %d@%s\n", count, period.appendRule(nil))
+		}
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Kept returns the indexes of the input snapshots which are required by the
+// policy, in input order.
+func (r Result) Kept() []int {
+	var idx []int
+	for i, why := range r.Reasons {
+		if len(why) != 0 {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// Pruned returns the indexes of the input snapshots which can be pruned, in
+// input order.
+func (r Result) Pruned() []int {
+	var idx []int
+	for i, why := range r.Reasons {
+		if len(why) == 0 {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// KeptTimes returns the kept snapshots, in input order. The snapshots must be
+// the ones the result was computed for.
+func (r Result) KeptTimes(snapshots []time.Time) []time.Time {
+	var ts []time.Time
+	for _, i := range r.Kept() {
+		ts = append(ts, snapshots[i])
+	}
+	return ts
+}
+
+// PrunedTimes returns the pruned snapshots, in input order. The snapshots must
+// be the ones the result was computed for.
+func (r Result) PrunedTimes(snapshots []time.Time) []time.Time {
+	var ts []time.Time
+	for _, i := range r.Pruned() {
+		ts = append(ts, snapshots[i])
+	}
+	return ts
+}
+
+// Phase is the state of a snapshot when pruning in two phases, where
+// snapshots are only pruned after they have been prunable for a grace period.
+type Phase int
+
+const (
+	PhaseKept     Phase = iota // required by the policy
+	PhaseExpiring              // prunable, but still within the grace period
+	PhaseExpired               // prunable for at least the grace period
+)
+
+// String returns the name of the phase.
+func (p Phase) String() string {
+	switch p {
+	case PhaseKept:
+		return "kept"
+	case PhaseExpiring:
+		return "expiring"
+	case PhaseExpired:
+		return "expired"
+	default:
+		return "Phase(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// Phases returns the phase of each snapshot for a two-phase prune (e.g., to
+// move snapshots to the trash before deleting them). The since slice contains
+// the time each snapshot was first found to be prunable by a previous run, or
+// the zero time if it wasn't (it may be nil). Snapshots which become required
+// by the policy again are no longer expiring. The returned since slice should
+// be saved for the next run.
+func (r Result) Phases(since []time.Time, now time.Time, grace time.Duration) ([]Phase, []time.Time) {
+	phases := make([]Phase, len(r.Reasons))
+	next := make([]time.Time, len(r.Reasons))
+	for i, reasons := range r.Reasons {
+		if len(reasons) != 0 {
+			phases[i] = PhaseKept
+			continue
+		}
+		t := now
+		if i < len(since) && !since[i].IsZero() {
+			t = since[i]
+		}
+		if now.Sub(t) >= grace {
+			phases[i] = PhaseExpired
+		} else {
+			phases[i] = PhaseExpiring
+		}
+		next[i] = t
+	}
+	return phases, next
+}
+
+// Summary summarizes the result of pruning a set of snapshots.
+type Summary struct {
+	Kept, Pruned int // number of snapshots
+
+	OldestKept, NewestKept     time.Time // zero if none were kept
+	OldestPruned, NewestPruned time.Time // zero if none were pruned
+}
+
+// Span returns the time covered by the kept snapshots.
+func (s Summary) Span() time.Duration {
+	return s.NewestKept.Sub(s.OldestKept)
+}
+
+// Summarize summarizes the result. The snapshots must be the ones the result
+// was computed for, but the result doesn't need to be one returned by
+// PruneResult (e.g., it may combine the results of pruning several groups).
+func (r Result) Summarize(snapshots []time.Time) Summary {
+	var s Summary
+	for i, reasons := range r.Reasons {
+		t := snapshots[i]
+		if len(reasons) != 0 {
+			if s.Kept++; s.Kept == 1 || t.Before(s.OldestKept) {
+				s.OldestKept = t
+			}
+			if s.Kept == 1 || t.After(s.NewestKept) {
+				s.NewestKept = t
+			}
+		} else {
+			if s.Pruned++; s.Pruned == 1 || t.Before(s.OldestPruned) {
+				s.OldestPruned = t
+			}
+			if s.Pruned == 1 || t.After(s.NewestPruned) {
+				s.NewestPruned = t
+			}
+		}
+	}
+	return s
+}
+
+// Span describes the time covered by the snapshots kept for a period.
+type Span struct {
+	Period Period
+	Kept   int // number of snapshots kept for the period
+
+	Oldest, Newest time.Time // oldest and newest snapshot kept for the period
+
+	// Intervals is the number of intervals of the period from the oldest to
+	// the newest kept snapshot, inclusive. If it is greater than Kept, some
+	// intervals in between did not have any snapshots.
+	Intervals int64
+}
+
+// Spans returns the span of the snapshots kept for each period (other than
+// Last and Within) in the policy, in order. The snapshots, location, and
+// options must be the ones the result was computed with.
+func (r Result) Spans(snapshots []time.Time, policy Policy, loc *time.Location, opt *PruneOptions) []Span {
+	if opt == nil {
+		opt = new(PruneOptions)
+	}
+	var spans []Span
+	policy.Each(func(period Period, _ int) {
+		if period.Unit == Last || period.Unit == Within {
+			return
+		}
+		span := Span{Period: period}
+		for i, reasons := range r.Reasons {
+			if slices.Contains(reasons, period) {
+				t := snapshots[i]
+				if span.Kept++; span.Kept == 1 || t.Before(span.Oldest) {
+					span.Oldest = t
+				}
+				if span.Kept == 1 || t.After(span.Newest) {
+					span.Newest = t
+				}
+			}
+		}
+		if span.Kept != 0 {
+			a, b := period.bucket(span.Oldest, loc, opt), period.bucket(span.Newest, loc, opt)
+			if period.Unit == Workdaily || period.Unit == Cron {
+				for span.Intervals = 1; a < b; span.Intervals++ {
+					a = period.nextBucket(a, opt)
+				}
+			} else {
+				span.Intervals = b - a + 1
+			}
+		}
+		spans = append(spans, span)
+	})
+	return spans
+}
+
+// PruneOptions contains additional options for PruneResult. The zero value is
+// equivalent to the behaviour of Prune.
+type PruneOptions struct {
+	// ReverseTies orders snapshots with identical timestamps by descending
+	// input index rather than ascending (i.e., the first one in the input is
+	// considered to be the newest).
+	ReverseTies bool
+
+	// Ordinals, if not nil, contains a secondary key for each snapshot (e.g., a
+	// build number), in the same order as the input snapshots. Snapshots with
+	// identical timestamps are ordered by ascending ordinal (i.e., the largest
+	// one is considered to be the newest) before ReverseTies applies. It must
+	// have the same length as the snapshots.
+	Ordinals []int64
+
+	// Calendar, if not nil, splits time into intervals instead of the
+	// Gregorian calendar configured by MonthMode, WeekMode, and
+	// FiscalYearStart (e.g., for a 4-4-5 retail calendar).
+	Calendar Calendar `json:"-"`
+
+	// Workdays, if not nil, determines the working days for periods with the
+	// Workdaily unit instead of Monday through Friday (e.g., to skip public
+	// holidays or market closures).
+	Workdays Workdays `json:"-"`
+
+	// MonthMode controls how monthly periods are split.
+	MonthMode MonthMode
+
+	// WeekMode controls the day weekly periods start on.
+	WeekMode WeekMode
+
+	// SelectMode controls which snapshot is kept for each interval.
+	SelectMode SelectMode
+
+	// Now is the reference time for periods with the Within unit. If zero, the
+	// current time according to Clock is used, or if Clock is nil, the newest
+	// snapshot.
+	Now time.Time
+
+	// Clock, if not nil, provides the current time when Now is zero (e.g.,
+	// a fake clock for deterministic tests).
+	Clock Clock `json:"-"`
+
+	// FiscalYearStart is the first month of the first quarter for quarterly
+	// periods (e.g., April for a fiscal year starting in April). If zero, it
+	// is January. Quarterly periods are not affected by MonthMode.
+	FiscalYearStart time.Month
+
+	// Pinned, if not nil, is called with the input index of each snapshot to
+	// check whether it must always be kept (e.g., a pre-upgrade backup). Pinned
+	// snapshots have a Pinned period as their last reason, and are preferred
+	// over the other snapshots in the same interval, so they still count
+	// towards fulfilling the policy.
+	Pinned func(i int) bool `json:"-"`
+
+	// Preferred, if not nil, is called with the input index of each snapshot
+	// to check whether it should be kept instead of the other snapshots in the
+	// same interval, regardless of SelectMode (but after pinned snapshots).
+	// Unlike Pinned, it does not keep snapshots which aren't needed by the
+	// policy. This is mainly useful for preferring the snapshots kept by a
+	// previous run, so changing the policy causes as little churn as possible.
+	Preferred func(i int) bool `json:"-"`
+
+	// Protected contains time ranges (e.g., around an audit) where all
+	// snapshots are pinned regardless of Pinned.
+	Protected []TimeRange
+
+	// Progress, if not nil, is called periodically while pruning with the
+	// number of steps done out of the total (the number of snapshots times the
+	// number of periods), and once more when finished (even if there aren't any
+	// snapshots).
+	Progress func(done, total int) `json:"-"`
+}
+
+// progressInterval is the number of steps between calls to
+// PruneOptions.Progress.
+const progressInterval = 1 << 16
+
+// TimeRange is a range of time.
+type TimeRange struct {
+	Start time.Time // inclusive
+	End   time.Time // exclusive
+}
+
+// Contains checks whether t is within the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// gregorian returns the default calendar for the options.
+func (o PruneOptions) gregorian() Gregorian {
+	return Gregorian{
+		MonthMode:       o.MonthMode,
+		WeekMode:        o.WeekMode,
+		FiscalYearStart: o.FiscalYearStart,
+	}
+}
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// MonthMode controls how monthly periods are split.
+type MonthMode int
+
+const (
+	// CalendarMonth splits monthly periods at the start of each calendar
+	// month, regardless of its length. Snapshots taken on the 29th-31st always
+	// belong to the month they were taken in, so a snapshot taken on the 31st
+	// of every month will be in the following month for shorter months (e.g.,
+	// Mar 1 or 2 instead of Feb 28 or 29).
+	CalendarMonth MonthMode = iota
+
+	// FixedMonth splits monthly periods into fixed 30-day windows, counting
+	// calendar days from 1970-01-01 in the pruning timezone. Month lengths and
+	// leap days do not affect the window boundaries, but the windows drift
+	// relative to calendar months by about 5 days per year.
+	FixedMonth
+)
+
+// WeekMode controls the day weekly periods start on.
+type WeekMode int
+
+const (
+	// MondayWeek starts weeks on Monday, as in ISO 8601.
+	MondayWeek WeekMode = iota
+
+	// SundayWeek starts weeks on Sunday.
+	SundayWeek
+)
+
+// SelectMode controls which snapshot is kept for each interval of a period
+// (other than ones with the Last or Within unit). Pinned snapshots are always
+// preferred.
+type SelectMode int
+
+const (
+	// FirstSnapshot keeps the oldest snapshot in each interval. Since the
+	// oldest snapshot in an interval does not change as newer ones are added,
+	// the kept snapshots are stable over repeated pruning.
+	FirstSnapshot SelectMode = iota
+
+	// LastSnapshot keeps the newest snapshot in each interval (e.g., the
+	// latest snapshot of each day). Until the interval ends, the snapshot kept
+	// for it changes whenever a newer one is added.
+	LastSnapshot
+
+	// ClosestSnapshot keeps the snapshot closest to the start of each interval
+	// ignoring Slack (e.g., the one closest to midnight for a daily period, or
+	// to the 1st for a monthly one), even if it was taken shortly before it.
+	// Each snapshot is considered for whichever interval start is closest to
+	// it, so the kept snapshots stay evenly spaced when the snapshot timing
+	// jitters. Note that Result.Spans still uses the actual intervals.
+	ClosestSnapshot
+)
+
+// firstDay returns the epoch day of the start of the week containing
+// 1970-01-01 (a Thursday).
+func (m WeekMode) firstDay() int64 {
+	if m == SundayWeek {
+		return -4
+	}
+	return -3
+}
+
+// Prune prunes the provided list of snapshots, returning a matching slice of
+// periods requiring that snapshot, and the remaining number of snapshots
+// required to fulfill the original policy.
+//
+// All snapshots are placed in the provided timezone, and the monotonic time
+// component is removed. The timezone affects the exact point at which calendar
+// days/months/years are split. Beware of duplicate timestamps at DST
 // transitions (if the offset isn't included whatever you use as the snapshot
 // name, and your timezone has DST, you may end up with two snapshots for
 // different times with the same name).
 //
+// Snapshots with identical timestamps are ordered by their index in the input,
+// so the last one is considered to be the newest.
+//
+// Calendar arithmetic may overflow outside the years 0 through 9999, so
+// snapshots outside that range are placed in the first or last interval of it
+// for periods other than Last and Within.
+//
+// Periods with the Within unit are relative to the newest snapshot. Use PruneAt
+// to evaluate them at a specific reference time instead.
+//
 // See pruneCorrectness in snappr_test.go for some additional notes about
 // guarantees provided by Prune.
+//
+// Deprecated: Use PruneResult, which returns the need as a Need rather than a
+// Policy with a different meaning for the counts.
 func Prune(snapshots []time.Time, policy Policy, loc *time.Location) (keep [][]Period, need Policy) {
-	need = policy.Clone()
-	keep = make([][]Period, len(snapshots))
+	r := PruneResult(snapshots, policy, loc, nil)
+	return r.Reasons, r.Need.Policy()
+}
+
+// PruneAt is like Prune, but evaluates the policy at the provided reference
+// time rather than the time of the newest snapshot, so the result is
+// reproducible even if snapshots are added after now (e.g., due to clock skew).
+//
+// Deprecated: Use PruneResult with PruneOptions.Now.
+func PruneAt(now time.Time, snapshots []time.Time, policy Policy, loc *time.Location) (keep [][]Period, need Policy) {
+	r := PruneResult(snapshots, policy, loc, &PruneOptions{Now: now})
+	return r.Reasons, r.Need.Policy()
+}
+
+// PruneResult is like Prune, but returns a Result and accepts additional
+// options. If opt is nil, the default options are used. It panics if
+// opt.Ordinals is set and has a different length than snapshots.
+func PruneResult(snapshots []time.Time, policy Policy, loc *time.Location, opt *PruneOptions) Result {
+	if opt == nil {
+		opt = new(PruneOptions)
+	}
+	if opt.Ordinals != nil && len(opt.Ordinals) != len(snapshots) {
+		panic(fmt.Sprintf("snappr: PruneOptions.Ordinals has %d elements, but there are %d snapshots", len(opt.Ordinals), len(snapshots)))
+	}
+
+	need := Need(policy.Clone())
+	keep := make([][]Period, len(snapshots))
 
 	if len(snapshots) == 0 {
-		return
+		if opt.Progress != nil {
+			opt.Progress(0, 0)
+		}
+		return Result{Reasons: keep, Need: need}
 	}
 
 	// sort the snapshots descending
@@ -340,55 +1782,127 @@ func Prune(snapshots []time.Time, policy Policy, loc *time.Location) (keep [][]P
 	for i := range sorted {
 		sorted[i] = i
 	}
-	slices.SortFunc(sorted, func(a, b int) int {
-		return snapshots[a].Compare(snapshots[b])
-	})
+	order := func(a, b int) int {
+		if x := snapshots[a].Compare(snapshots[b]); x != 0 {
+			return x
+		}
+		if opt.Ordinals != nil {
+			if x := cmp.Compare(opt.Ordinals[a], opt.Ordinals[b]); x != 0 {
+				return x
+			}
+		}
+		if opt.ReverseTies {
+			return cmp.Compare(b, a)
+		}
+		return cmp.Compare(a, b)
+	}
+	if !slices.IsSortedFunc(sorted, order) { // usually already in order
+		slices.SortFunc(sorted, order)
+	}
+
+	rank := make([]int, len(snapshots))
+	for i, at := range sorted {
+		rank[at] = len(sorted) - 1 - i
+	}
+
+	pinned := make([]bool, len(snapshots))
+	for i := range pinned {
+		if opt.Pinned != nil {
+			pinned[i] = opt.Pinned(i)
+		}
+		for _, r := range opt.Protected {
+			pinned[i] = pinned[i] || r.Contains(snapshots[i])
+		}
+	}
+
+	var preferred []bool
+	if opt.Preferred != nil {
+		preferred = make([]bool, len(snapshots))
+		for i := range preferred {
+			preferred[i] = opt.Preferred(i)
+		}
+	}
+	isPreferred := func(i int) bool {
+		return preferred != nil && preferred[i]
+	}
 
+	now := opt.Now
+	if now.IsZero() {
+		if opt.Clock != nil {
+			now = opt.Clock.Now()
+		} else {
+			now = snapshots[sorted[len(sorted)-1]]
+		}
+	}
+	var done, total int
+	if opt.Progress != nil {
+		total = len(snapshots) * len(policy.count)
+		defer func() {
+			opt.Progress(total, total)
+		}()
+	}
 	policy.Each(func(period Period, count int) {
 		var (
 			match = make([]bool, len(snapshots))
 			last  int64 // period index
 			prev  bool
+			first int       // sorted index of the current match
+			pin   bool      // whether the current match is pinned
+			pref  bool      // whether the current match is preferred
+			start time.Time // of the current interval, for ClosestSnapshot
 		)
-		// start from the beginning, marking the first one in each period
+		// start from the beginning, marking one in each period
 		for i := range snapshots {
-			var current int64
-			switch t := snapshots[sorted[i]].In(loc).Truncate(-1); period.Unit {
-			case Last:
-				match[i] = true
+			if opt.Progress != nil {
+				if done++; done%progressInterval == 0 {
+					opt.Progress(done, total)
+				}
+			}
+			if period.Unit == Last {
+				match[i] = (len(snapshots)-1-i)%period.Interval == 0
+				continue
+			}
+			if period.Unit == Within {
+				match[i] = snapshots[sorted[i]].After(now.Add(-time.Duration(period.Interval) * time.Second))
 				continue
-			case Secondly:
-				current = t.Unix()
-			case Daily:
-				n, x := t.Year(), 0
-
-				x = n / 400
-				current += int64(x * (365*400 + 97)) // days per 400 years
-				n -= x * 400
-
-				x = n / 100
-				current += int64(x * (365*100 + 24)) // days per 100 years
-				n -= x * 100
-
-				x = n / 4
-				current += int64(x * (365*4 + 1)) // days per 4 years
-				n -= x * 4
-
-				current += int64(x) + int64(t.YearDay())
-			case Monthly:
-				year, month, _ := t.Date()
-				current = (int64(year)*12 + int64(month))
-			case Yearly:
-				current = int64(t.Year())
-			default:
-				panic("wtf")
 			}
-			current /= int64(period.Interval)
+			t := snapshots[sorted[i]]
+			current := period.bucket(t, loc, opt)
+			if opt.SelectMode == ClosestSnapshot {
+				// use the closest interval start instead
+				a := period.bucketStart(current, loc, opt).Add(period.Slack)
+				b := period.bucketStart(period.nextBucket(current, opt), loc, opt).Add(period.Slack)
+				if b.Sub(t) < t.Sub(a).Abs() {
+					current = period.nextBucket(current, opt)
+				}
+			}
 
 			if !prev || current != last {
 				match[i] = true
 				last = current
 				prev = true
+				first, pin, pref = i, pinned[sorted[i]], isPreferred(sorted[i])
+				if opt.SelectMode == ClosestSnapshot {
+					start = period.bucketStart(current, loc, opt).Add(period.Slack)
+				}
+				continue
+			}
+			var replace bool
+			switch {
+			case pin != pinned[sorted[i]]:
+				replace = !pin // prefer pinned snapshots
+			case pref != isPreferred(sorted[i]):
+				replace = !pref
+			case opt.SelectMode == LastSnapshot:
+				replace = true
+			case opt.SelectMode == ClosestSnapshot:
+				a := snapshots[sorted[first]].Sub(start).Abs()
+				b := snapshots[sorted[i]].Sub(start).Abs()
+				replace = b < a
+			}
+			if replace {
+				match[first], match[i] = false, true
+				first, pin, pref = i, pinned[sorted[i]], isPreferred(sorted[i])
 			}
 		}
 		// preserve from the end and stay within the count
@@ -407,5 +1921,323 @@ func Prune(snapshots []time.Time, policy Policy, loc *time.Location) (keep [][]P
 		}
 		need.count[period] = count
 	})
-	return
+	for i, x := range pinned {
+		if x {
+			keep[i] = append(keep[i], Period{Unit: Pinned, Interval: 1})
+		}
+	}
+	return Result{Reasons: keep, Need: need, sorted: sorted, rank: rank}
+}
+
+// PruneOrdinal is like PruneResult, but prunes arbitrary integers which
+// increase over time (e.g., build numbers or version counters) rather than
+// timestamps. Only the Last and Ordinal units are meaningful for ordinals (see
+// Policy.IsOrdinal); other units treat the values as Unix timestamps in UTC.
+func PruneOrdinal(values []int64, policy Policy, opt *PruneOptions) Result {
+	snapshots := make([]time.Time, len(values))
+	for i, v := range values {
+		snapshots[i] = time.Unix(v, 0).UTC()
+	}
+	return PruneResult(snapshots, policy, time.UTC, opt)
+}
+
+// PruneFunc is like Prune, but prunes arbitrary items (e.g., structs describing
+// snapshots) using the time returned by timeOf for each one, and returns the
+// kept and pruned items in input order.
+func PruneFunc[T any](items []T, timeOf func(T) time.Time, policy Policy, loc *time.Location) (kept, pruned []T) {
+	snapshots := make([]time.Time, len(items))
+	for i, item := range items {
+		snapshots[i] = timeOf(item)
+	}
+	for i, reasons := range PruneResult(snapshots, policy, loc, nil).Reasons {
+		if len(reasons) != 0 {
+			kept = append(kept, items[i])
+		} else {
+			pruned = append(pruned, items[i])
+		}
+	}
+	return kept, pruned
+}
+
+// PruneSeq is like PruneResult with the default options, but reads the
+// snapshots from seq (which has the same signature as iter.Seq2[int,
+// time.Time]) rather than a slice, and only holds on to the ones which could
+// still be kept, so huge numbers of snapshots can be pruned without having all
+// of them in memory at once. It returns the periods requiring each kept
+// snapshot by the index yielded with it; all other snapshots can be pruned.
+//
+// The snapshots must be yielded in ascending order, and snapshots with
+// identical timestamps are considered newer than the ones yielded before them.
+// Memory use is proportional to the number of snapshots kept, except for Last
+// periods with an interval and an unlimited count, which need the index of
+// every snapshot.
+func PruneSeq(seq func(yield func(int, time.Time) bool), policy Policy, loc *time.Location) (map[int][]Period, Need, error) {
+	type snapshot struct {
+		index int
+		time  time.Time
+	}
+	type state struct {
+		period Period
+		count  int
+		match  []snapshot // oldest first
+		last   int64      // period index
+	}
+	var states []*state
+	policy.Each(func(period Period, count int) {
+		if count != 0 {
+			states = append(states, &state{period: period, count: count})
+		}
+	})
+
+	var (
+		n    int
+		prev time.Time
+		err  error
+		opt  = new(PruneOptions)
+	)
+	seq(func(index int, t time.Time) bool {
+		if n != 0 && t.Before(prev) {
+			err = fmt.Errorf("snapshot %d (%s) is older than the previous one (%s)", index, t, prev)
+			return false
+		}
+		for _, s := range states {
+			switch s.period.Unit {
+			case Last:
+				s.match = append(s.match, snapshot{index, t})
+				if s.count > 0 {
+					if n := (s.count-1)*s.period.Interval + 1; len(s.match) > n {
+						s.match = s.match[len(s.match)-n:]
+					}
+				}
+			case Within:
+				s.match = append(s.match, snapshot{index, t})
+				cutoff := t.Add(-time.Duration(s.period.Interval) * time.Second)
+				for len(s.match) != 0 && ((s.count > 0 && len(s.match) > s.count) || !s.match[0].time.After(cutoff)) {
+					s.match = s.match[1:]
+				}
+			default:
+				// keep the first snapshot in each interval
+				if current := s.period.bucket(t, loc, opt); n == 0 || current != s.last {
+					s.last = current
+					if s.match = append(s.match, snapshot{index, t}); s.count > 0 && len(s.match) > s.count {
+						s.match = s.match[1:]
+					}
+				}
+			}
+		}
+		n++
+		prev = t
+		return true
+	})
+	if err != nil {
+		return nil, Need{}, err
+	}
+
+	keep := map[int][]Period{}
+	need := Need(policy.Clone())
+	for _, s := range states {
+		count := s.count
+		for i := len(s.match) - 1; i >= 0 && count != 0; i-- {
+			if s.period.Unit == Last && (len(s.match)-1-i)%s.period.Interval != 0 {
+				continue
+			}
+			if count > 0 {
+				count--
+			}
+			keep[s.match[i].index] = append(keep[s.match[i].index], s.period)
+		}
+		need.count[s.period] = count
+	}
+	return keep, need, nil
+}
+
+// formatSeconds formats a number of seconds as a duration, omitting trailing
+// zero units.
+func formatSeconds(n int) string {
+	return formatDuration(time.Second * time.Duration(n))
+}
+
+// formatDuration formats a duration, omitting trailing zero units.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if v, ok := strings.CutSuffix(s, "m0s"); ok {
+		s = v + "m"
+	}
+	if v, ok := strings.CutSuffix(s, "h0m"); ok {
+		s = v + "h"
+	}
+	return s
+}
+
+// location returns the location to split intervals in, which is the period's
+// zone if set, or loc otherwise.
+func (p Period) location(loc *time.Location) *time.Location {
+	if p.Zone != "" {
+		if z, err := loadZone(p.Zone); err == nil {
+			return z
+		}
+	}
+	return loc
+}
+
+var (
+	zones    sync.Map // map[string]*time.Location
+	zoneInfo struct {
+		sync.RWMutex
+		fsys fs.FS
+	}
+)
+
+// SetZoneInfo sets an additional source of time zone data for LoadLocation
+// (and Period.Zone) containing zoneinfo files by name (e.g., os.DirFS on a
+// zoneinfo directory, or zip.OpenReader on a zoneinfo.zip), for systems
+// without time zone data. Zones which aren't found in it are loaded using
+// time.LoadLocation. If nil, only time.LoadLocation is used. It should be
+// called before pruning any snapshots.
+func SetZoneInfo(fsys fs.FS) {
+	zoneInfo.Lock()
+	defer zoneInfo.Unlock()
+	zoneInfo.fsys = fsys
+	zones.Range(func(k, _ any) bool {
+		zones.Delete(k)
+		return true
+	})
+}
+
+// LoadLocation is like time.LoadLocation, but also uses the time zone data set
+// by SetZoneInfo, and caches the result.
+func LoadLocation(name string) (*time.Location, error) {
+	return loadZone(name)
+}
+
+// loadZone is like time.LoadLocation, but caches the result.
+func loadZone(name string) (*time.Location, error) {
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	zoneInfo.RLock()
+	defer zoneInfo.RUnlock()
+	if zoneInfo.fsys != nil && fs.ValidPath(name) {
+		if buf, err := fs.ReadFile(zoneInfo.fsys, name); err == nil {
+			loc, err := time.LoadLocationFromTZData(name, buf)
+			if err != nil {
+				return nil, err
+			}
+			zones.Store(name, loc)
+			return loc, nil
+		}
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zones.Store(name, loc)
+	return loc, nil
+}
+
+// bucket returns the index of the interval containing t using the calendar
+// from opt. The unit must not be Last or Within.
+func (p Period) bucket(t time.Time, loc *time.Location, opt *PruneOptions) int64 {
+	t = t.In(p.location(loc)).Truncate(-1)
+	if p.Slack != 0 {
+		t = t.Add(p.Slack)
+	}
+	t = clampYear(t)
+	switch p.Unit {
+	case Workdaily:
+		return opt.workday(epochDay(t), false)
+	case Cron:
+		return p.cron().prevCivil(t).Unix()
+	}
+	if opt.Calendar != nil {
+		return opt.Calendar.BucketKey(t, p)
+	}
+	return opt.gregorian().BucketKey(t, p)
+}
+
+// clampYear limits t to the years 0 through 9999 in its location.
+func clampYear(t time.Time) time.Time {
+	if y := t.Year(); y < 0 {
+		return time.Date(0, 1, 1, 0, 0, 0, 0, t.Location())
+	} else if y > 9999 {
+		return time.Date(9999, 12, 31, 23, 59, 59, 999999999, t.Location())
+	}
+	return t
+}
+
+// bucketStart returns the start of the interval with index i. It is the
+// inverse of bucket.
+func (p Period) bucketStart(i int64, loc *time.Location, opt *PruneOptions) time.Time {
+	var t time.Time
+	if p.Unit == Workdaily {
+		t = startOfDay(1970, 1, 1+int(i), p.location(loc))
+	} else if p.Unit == Cron {
+		t = fromCivil(time.Unix(i, 0).UTC(), p.location(loc))
+	} else if g, ok := opt.Calendar.(Gregorian); ok || opt.Calendar == nil {
+		if !ok {
+			g = opt.gregorian()
+		}
+		t = g.bucketStart(i, p, p.location(loc))
+	} else {
+		// find the first second in the interval
+		lo, hi := int64(-1<<40), int64(1<<40)
+		for lo < hi {
+			if m := lo + (hi-lo)/2; opt.Calendar.BucketKey(time.Unix(m, 0).In(p.location(loc)), p) < i {
+				lo = m + 1
+			} else {
+				hi = m
+			}
+		}
+		t = time.Unix(lo, 0).In(p.location(loc))
+	}
+	if p.Slack != 0 {
+		t = t.Add(-p.Slack)
+	}
+	return t
+}
+
+// nextBucket returns the index of the interval after i.
+func (p Period) nextBucket(i int64, opt *PruneOptions) int64 {
+	switch p.Unit {
+	case Workdaily:
+		return opt.workday(i, true)
+	case Cron:
+		return p.cron().next(time.Unix(i, 0).UTC()).Unix()
+	}
+	return i + 1
+}
+
+// startOfDay returns the first instant of the specified (possibly
+// denormalized) date in loc, which may not be midnight if a DST transition
+// skips it.
+func startOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if y, m, d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Date(); t.Year() != y || t.Month() != m || t.Day() != d {
+		if _, end := t.ZoneBounds(); !end.IsZero() {
+			t = end // normalized to before the transition
+		}
+	}
+	return t
+}
+
+// epochDay returns the number of calendar days between 1970-01-01 and the date
+// of t in its location.
+func epochDay(t time.Time) int64 {
+	_, offset := t.Zone()
+	return floorDiv(t.Unix()+int64(offset), 24*60*60)
+}
+
+// floorMod returns the remainder of floorDiv(a, b), which has the same sign as
+// b.
+func floorMod(a, b int64) int64 {
+	return a - floorDiv(a, b)*b
+}
+
+// floorDiv divides a by b, rounding towards negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
 }
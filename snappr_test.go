@@ -3,19 +3,180 @@ package snappr
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"maps"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 	_ "time/tzdata"
+
+	"golang.org/x/tools/txtar"
 )
 
+func TestUnitText(t *testing.T) {
+	for u := Unit(0); u < numUnits; u++ {
+		b, err := u.MarshalText()
+		if err != nil {
+			t.Fatalf("marshal %s: unexpected error %v", u, err)
+		}
+		if string(b) != u.String() {
+			t.Errorf("marshal %s: expected %q, got %q", u, u.String(), b)
+		}
+		var v Unit
+		if err := v.UnmarshalText(b); err != nil {
+			t.Fatalf("unmarshal %q: unexpected error %v", b, err)
+		}
+		if v != u {
+			t.Errorf("unmarshal %q: expected %s, got %s", b, u, v)
+		}
+	}
+	if _, err := numUnits.MarshalText(); err == nil {
+		t.Errorf("marshal invalid unit: expected error")
+	}
+	if err := new(Unit).UnmarshalText([]byte("fortnightly")); err == nil {
+		t.Errorf("unmarshal unknown unit: expected error")
+	}
+}
+
+func TestUnitValues(t *testing.T) {
+	// the original units must keep their values
+	for i, u := range []Unit{Last, Secondly, Daily, Monthly, Yearly} {
+		if int(u) != i {
+			t.Errorf("unit %s: expected value %d, got %d", u, i, u)
+		}
+	}
+	for i := 1; i < len(unitOrder); i++ {
+		if a, b := unitOrder[i-1], unitOrder[i]; a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Errorf("compare %s %s: expected %s to sort first", a, b, a)
+		}
+	}
+	seen := map[Unit]bool{}
+	for _, u := range unitOrder {
+		if !u.IsValid() || seen[u] {
+			t.Errorf("unit order: invalid or duplicate unit %s", u)
+		}
+		seen[u] = true
+	}
+}
+
+func TestJSON(t *testing.T) {
+	if b, err := json.Marshal([]Unit{Daily, Within}); err != nil || string(b) != `["daily","within"]` {
+		t.Errorf("marshal units: got %s (error: %v)", b, err)
+	}
+
+	for _, tc := range []struct {
+		json string
+		exp  Period
+	}{
+		{`"daily"`, Period{Unit: Daily, Interval: 1}},
+		{`"secondly:1h+30m~1m/UTC"`, Period{Unit: Secondly, Interval: 3600, Offset: 1800, Slack: time.Minute, Zone: "UTC"}},
+		{`"within:24h"`, Period{Unit: Within, Interval: 86400}},
+		{`"pinned"`, Period{Unit: Pinned, Interval: 1}},
+		{`{"unit": "monthly", "interval": 2, "offset": 3}`, Period{Unit: Monthly, Interval: 2, Offset: 1}},
+		{`{"unit": "daily", "slack": "5m", "zone": "UTC"}`, Period{Unit: Daily, Interval: 1, Slack: 5 * time.Minute, Zone: "UTC"}},
+		{`{"Unit": "daily", "Interval": 1, "Offset": 0, "Slack": 300000000000, "Zone": ""}`, Period{Unit: Daily, Interval: 1, Slack: 5 * time.Minute}},
+	} {
+		var act Period
+		if err := json.Unmarshal([]byte(tc.json), &act); err != nil {
+			t.Errorf("unmarshal period %s: unexpected error: %v", tc.json, err)
+			continue
+		}
+		if act != tc.exp {
+			t.Errorf("unmarshal period %s: expected %#v, got %#v", tc.json, tc.exp, act)
+		}
+		b, err := json.Marshal(act)
+		if err != nil {
+			t.Errorf("marshal period %s: unexpected error: %v", act, err)
+			continue
+		}
+		var rt Period
+		if err := json.Unmarshal(b, &rt); err != nil || rt != act {
+			t.Errorf("round-trip period %s: got %#v from %s (error: %v)", act, rt, b, err)
+		}
+	}
+	for _, str := range []string{`"3@daily"`, `"daily weekly"`, `"log@daily"`, `"fortnightly"`, `{"interval": 1}`, `{"unit": "daily", "interval": -1}`, `{"unit": "daily", "slack": "x"}`, `1`} {
+		var p Period
+		if err := json.Unmarshal([]byte(str), &p); err == nil {
+			t.Errorf("unmarshal period %s: expected error", str)
+		}
+	}
+
+	for _, tc := range []struct {
+		json string
+		exp  string
+	}{
+		{`"3@last 7@daily yearly"`, `"3@last 7@daily yearly"`},
+		{`{"last": 3, "daily": 7, "yearly": -1}`, `"3@last 7@daily yearly"`},
+		{`{"secondly:1h~1m": 6}`, `"6@secondly:1h~1m"`},
+	} {
+		var p Policy
+		if err := json.Unmarshal([]byte(tc.json), &p); err != nil {
+			t.Errorf("unmarshal policy %s: unexpected error: %v", tc.json, err)
+			continue
+		}
+		if b, err := json.Marshal(p); err != nil || string(b) != tc.exp {
+			t.Errorf("marshal policy %s: expected %s, got %s (error: %v)", tc.json, tc.exp, b, err)
+		}
+	}
+	for _, str := range []string{`"3@fortnightly"`, `{"daily": 0}`, `{"daily": 1, "daily:1": 2}`, `{"pinned": 1}`, `{"3@daily": 1}`, `[]`} {
+		var p Policy
+		if err := json.Unmarshal([]byte(str), &p); err == nil {
+			t.Errorf("unmarshal policy %s: expected error", str)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, tc := range []struct {
+		rule, canonical string
+		exp             Period
+	}{
+		{"daily:7", "daily:7", Period{Unit: Daily, Interval: 7}},
+		{"Daily:7+3", "daily:7+3", Period{Unit: Daily, Interval: 7, Offset: 3}},
+		{"last:1", "last", Period{Unit: Last, Interval: 1}},
+		{"secondly:3600~5m/UTC", "secondly:1h~5m/UTC", Period{Unit: Secondly, Interval: 3600, Slack: 5 * time.Minute, Zone: "UTC"}},
+		{"within:48h", "within:48h", Period{Unit: Within, Interval: 172800}},
+		{"yearly~1h@anchor=4-1", "yearly~1h@anchor=04-01", Period{Unit: Yearly, Interval: 1, Slack: time.Hour, Anchor: "04-01"}},
+		{"secondly:500ms", "millisecondly:500", Period{Unit: Millisecondly, Interval: 500}},
+		{"WorkDaily~1h", "workdaily~1h", Period{Unit: Workdaily, Interval: 1, Slack: time.Hour}},
+		{"cron:0_3_*/2_*_SUN/UTC", "cron:0_3_*/2_*_sun/UTC", Period{Unit: Cron, Interval: 1, Zone: "UTC", Cron: "0 3 */2 * sun"}},
+	} {
+		act, err := ParsePeriod(tc.rule)
+		if err != nil {
+			t.Errorf("parse %q: unexpected error: %v", tc.rule, err)
+			continue
+		}
+		if act != tc.exp {
+			t.Errorf("parse %q: expected %#v, got %#v", tc.rule, tc.exp, act)
+		}
+		if b, err := act.MarshalText(); err != nil || string(b) != tc.canonical {
+			t.Errorf("marshal %q: expected %q, got %q (error: %v)", tc.rule, tc.canonical, b, err)
+		}
+		var rt Period
+		if err := rt.UnmarshalText([]byte(tc.canonical)); err != nil || rt != act {
+			t.Errorf("unmarshal %q: expected %#v, got %#v (error: %v)", tc.canonical, act, rt, err)
+		}
+	}
+	for _, rule := range []string{"", "7@daily", "daily weekly", "log@daily", "tiers:1dx7", "fortnightly", "daily:0", "within"} {
+		if _, err := ParsePeriod(rule); err == nil {
+			t.Errorf("parse %q: expected error", rule)
+		}
+	}
+	if _, err := (Period{Unit: Daily}).MarshalText(); err == nil {
+		t.Errorf("marshal invalid period: expected error")
+	}
+}
+
 func TestParsePolicy(t *testing.T) {
 	for _, tc := range []func(*Policy) string{
 		func(p *Policy) string {
@@ -36,7 +197,47 @@ func TestParsePolicy(t *testing.T) {
 			return "yearly:0"
 		},
 		func(p *Policy) string {
-			return "last:2"
+			p.MustSet(Last, 1, 3)
+			p.MustSet(Last, 2, -1)
+			return "3@last last:2"
+		},
+		func(p *Policy) string {
+			return "last:0"
+		},
+		func(p *Policy) string {
+			p.SetWithin(24 * time.Hour)
+			p.MustSet(Within, 90, 3)
+			return "within:24h 3@within:90"
+		},
+		func(p *Policy) string {
+			return "within"
+		},
+		func(p *Policy) string {
+			p.MustSet(Last, 1, 10)
+			p.MustSet(Ordinal, 100, -1)
+			p.Set(Period{Unit: Ordinal, Interval: 10, Offset: 5}, 3)
+			return "10@last ordinal:100 3@ordinal:10+5"
+		},
+		func(p *Policy) string {
+			return "ordinal:10~1s"
+		},
+		func(p *Policy) string {
+			return "ordinal/UTC"
+		},
+		func(p *Policy) string {
+			return "within:1h+30m"
+		},
+		func(p *Policy) string {
+			return "within:1h~1m"
+		},
+		func(p *Policy) string {
+			return "within:1h/UTC"
+		},
+		func(p *Policy) string {
+			return "log@within:max=10"
+		},
+		func(p *Policy) string {
+			return "last:5+2"
 		},
 		func(p *Policy) string {
 			return "0@last:1"
@@ -48,8 +249,20 @@ func TestParsePolicy(t *testing.T) {
 			return "secondly:1ns"
 		},
 		func(p *Policy) string {
+			p.MustSet(Millisecondly, 999, -1)
 			return "secondly:999ms"
 		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Millisecondly, Interval: 1500, Offset: 1000, Slack: 100 * time.Millisecond}, 10)
+			p.Set(Period{Unit: Millisecondly, Interval: 250, Offset: 50}, -1)
+			return "10@secondly:1.5s+1~100ms millisecondly:250ms+50"
+		},
+		func(p *Policy) string {
+			return "secondly:1500us"
+		},
+		func(p *Policy) string {
+			return "secondly:500ms~500ms"
+		},
 		func(p *Policy) string {
 			p.MustSet(Secondly, 1, -1)
 			return "secondly:1000ms"
@@ -63,6 +276,232 @@ func TestParsePolicy(t *testing.T) {
 		func(p *Policy) string {
 			return "secondly:1h0"
 		},
+		func(p *Policy) string {
+			return "yearly:2+2"
+		},
+		func(p *Policy) string {
+			return "yearly:2+-1"
+		},
+		func(p *Policy) string {
+			return "last+1"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Yearly, Interval: 2}, -1)
+			p.Set(Period{Unit: Yearly, Interval: 2, Offset: 1}, 3)
+			p.Set(Period{Unit: Monthly, Interval: 3, Offset: 2}, 4)
+			p.Set(Period{Unit: Secondly, Interval: 3600, Offset: 1800}, 5)
+			return "yearly:2 3@yearly:2+1 4@monthly:3+2 5@secondly:3600+1800"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Secondly, Interval: 3600, Offset: 1800}, 24)
+			p.Set(Period{Unit: Secondly, Interval: 86400, Offset: 3*3600 + 15*60}, 7)
+			p.Set(Period{Unit: Secondly, Interval: 300, Offset: 30}, 12)
+			return "24@secondly:1h+30m 7@secondly:24h+3h15m 12@secondly:5m+30s"
+		},
+		func(p *Policy) string {
+			return "secondly:1h+1h"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Daily, Interval: 1, Slack: 5 * time.Minute}, 7)
+			p.Set(Period{Unit: Secondly, Interval: 3600, Offset: 1800, Slack: 90 * time.Second}, 24)
+			p.Set(Period{Unit: Monthly, Interval: 3, Slack: time.Hour}, -1)
+			return "7@daily~5m 24@secondly:1h+30m~1m30s monthly:3~1h"
+		},
+		func(p *Policy) string {
+			return "last~1s"
+		},
+		func(p *Policy) string {
+			return "secondly:60~1m"
+		},
+		func(p *Policy) string {
+			return "daily~-1s"
+		},
+		func(p *Policy) string {
+			for _, x := range []int{1, 2, 4, 8, 16, 32, 64, 128, 256} {
+				p.MustSet(Daily, x, 2)
+			}
+			return "log@daily:base=2,min=1,max=365"
+		},
+		func(p *Policy) string {
+			p.MustSet(Monthly, 2, 3)
+			p.MustSet(Monthly, 6, 2)
+			p.Set(Period{Unit: Secondly, Interval: 3600, Slack: time.Minute}, 2)
+			p.Set(Period{Unit: Secondly, Interval: 7200, Slack: time.Minute}, 2)
+			p.Set(Period{Unit: Secondly, Interval: 14400, Slack: time.Minute}, 2)
+			return "log@monthly:base=3,min=2,max=12 log@secondly:min=1h,max=6h~1m"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Secondly, Interval: 3600}, 24)
+			p.MustSet(Daily, 1, 30)
+			p.MustSet(Daily, 7, 52)
+			p.MustSet(Monthly, 1, -1)
+			p.MustSet(Yearly, 2, 3)
+			p.MustSet(Secondly, 900, 4)
+			return "tiers:1h×24,1d×30,1w×52,1m×inf TIERS:2yx3,15minx4"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Secondly, Interval: 3600}, 24)
+			p.Set(Period{Unit: Daily, Interval: 1, Zone: "America/Toronto"}, 30)
+			p.Set(Period{Unit: Monthly, Interval: 1, Zone: "UTC"}, -1)
+			return "tiers:1h×24,1d×30/America/Toronto,1m×inf/UTC"
+		},
+		func(p *Policy) string {
+			return "tiers:1dx7,1wx4,7dx2"
+		},
+		func(p *Policy) string {
+			return "tiers:1dx7/Nowhere"
+		},
+		func(p *Policy) string {
+			return "tiers:1dx7/"
+		},
+		func(p *Policy) string {
+			return "tiers:1dx0"
+		},
+		func(p *Policy) string {
+			return "tiers:1qx1"
+		},
+		func(p *Policy) string {
+			return "tiers:dx1"
+		},
+		func(p *Policy) string {
+			return "tiers:1d"
+		},
+		func(p *Policy) string {
+			return "log@daily:min=1"
+		},
+		func(p *Policy) string {
+			return "log@daily:base=1,max=10"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Yearly, Interval: 1, Zone: "UTC"}, -1)
+			p.Set(Period{Unit: Daily, Interval: 1, Slack: time.Minute, Zone: "America/Toronto"}, 7)
+			p.MustSet(Daily, 1, 3)
+			return "yearly/UTC 7@daily~1m/America/Toronto 3@daily"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Yearly, Interval: 1, Zone: "UTC", Anchor: "04-01"}, 7)
+			p.Set(Period{Unit: Daily, Interval: 14, Offset: 7, Anchor: "monday"}, -1)
+			p.Set(Period{Unit: Monthly, Interval: 1, Anchor: "15"}, 12)
+			p.Set(Period{Unit: Quarterly, Interval: 1, Anchor: "02-10"}, 4)
+			return "7@yearly/UTC@anchor=04-01 daily:14+7@anchor=Monday 12@monthly@anchor=15 4@quarterly@anchor=2-10"
+		},
+		func(p *Policy) string {
+			return "daily@anchor=someday"
+		},
+		func(p *Policy) string {
+			return "monthly@anchor=29"
+		},
+		func(p *Policy) string {
+			return "yearly@anchor=13-01"
+		},
+		func(p *Policy) string {
+			return "secondly:1h@anchor=monday"
+		},
+		func(p *Policy) string {
+			return "daily@anchor="
+		},
+		func(p *Policy) string {
+			return "log@daily:max=30@anchor=monday"
+		},
+		func(p *Policy) string {
+			p.Set(Period{Unit: Cron, Interval: 1, Cron: "0 3 * * sun"}, 4)
+			p.Set(Period{Unit: Cron, Interval: 1, Slack: time.Hour, Zone: "America/Toronto", Cron: "*/15 9-17 1,15 * mon-fri"}, -1)
+			return "4@cron:0_3_*_*_Sun cron:*/15_9-17_1,15_*_mon-fri~1h/America/Toronto"
+		},
+		func(p *Policy) string {
+			p.MustSet(Workdaily, 1, 10)
+			p.Set(Period{Unit: Workdaily, Interval: 1, Zone: "America/New_York"}, -1)
+			return "10@workdaily workdaily/America/New_York"
+		},
+		func(p *Policy) string {
+			return "workdaily:2"
+		},
+		func(p *Policy) string {
+			return "workdaily@anchor=monday"
+		},
+		func(p *Policy) string {
+			return "log@workdaily:max=30"
+		},
+		func(p *Policy) string {
+			return "cron:0_3_*_*"
+		},
+		func(p *Policy) string {
+			return "cron:0_0_31_2_*"
+		},
+		func(p *Policy) string {
+			return "cron:0_3_*_*_sun@anchor=monday"
+		},
+		func(p *Policy) string {
+			return "cron"
+		},
+		func(p *Policy) string {
+			return "log@cron:0_3_*_*_*"
+		},
+		func(p *Policy) string {
+			p.MustSet(Weekly, 1, 4)
+			p.MustSet(Weekly, 2, 6)
+			p.Set(Period{Unit: Weekly, Interval: 4, Offset: 1, Slack: time.Hour}, -1)
+			return "4@weekly 6@weekly:2 weekly:4+1~1h"
+		},
+		func(p *Policy) string {
+			p.MustSet(Quarterly, 1, 4)
+			p.MustSet(Quarterly, 4, -1)
+			p.MustSet(Monthly, 3, 2)
+			return "4@quarterly quarterly:4 tiers:3m×2"
+		},
+		func(p *Policy) string {
+			p.MustSet(Minutely, 5, 30)
+			p.Set(Period{Unit: Minutely, Interval: 60, Offset: 30, Slack: 10 * time.Second}, 24)
+			return "30@minutely:5 24@minutely:60+30~10s"
+		},
+		func(p *Policy) string {
+			return "minutely:5~5m"
+		},
+		func(p *Policy) string {
+			return "minutely:5m"
+		},
+		func(p *Policy) string {
+			return "daily/Nowhere/Invalid"
+		},
+		func(p *Policy) string {
+			return "daily/"
+		},
+		func(p *Policy) string {
+			return "1@last/UTC"
+		},
+		func(p *Policy) string {
+			return "4294967296@daily"
+		},
+		func(p *Policy) string {
+			return "daily:2147483648"
+		},
+		func(p *Policy) string {
+			return "tiers:1000000000hx1"
+		},
+		func(p *Policy) string {
+			return "tiers:5124095576030431h×1"
+		},
+		func(p *Policy) string {
+			return "99999999999999999999@daily"
+		},
+		func(p *Policy) string {
+			return "secondly:1000000h"
+		},
+		func(p *Policy) string {
+			return "tiers:1000000h×1"
+		},
+		func(p *Policy) string {
+			return "log@daily:max=4294967296"
+		},
+		func(p *Policy) string {
+			return "log@daily:max=10+1"
+		},
+		func(p *Policy) string {
+			return "log@last:max=10"
+		},
+		func(p *Policy) string {
+			return "log@daily:max=10 daily:4"
+		},
 		func(p *Policy) string {
 			p.MustSet(Yearly, 5, -1)
 			p.MustSet(Yearly, 1, 2)
@@ -145,7 +584,8 @@ func pruneCorrectness(snapshots []time.Time, policy Policy, loc *time.Location)
 		allSnapshots := snapshots
 		snapshots := snapshots[:subset]
 
-		keep, need := Prune(snapshots, policy, loc)
+		r := PruneResult(snapshots, policy, loc, nil)
+		keep, need := r.Reasons, r.Need.Policy()
 
 		/**
 		 * Prune "keep" output will be like the input snapshots, but with a
@@ -172,6 +612,28 @@ func pruneCorrectness(snapshots []time.Time, policy Policy, loc *time.Location)
 			}
 		}
 
+		/**
+		 * PruneResult will expose the order snapshots were processed in (oldest
+		 * first) and the recency rank of each snapshot.
+		 */
+		{
+			sorted := r.SortedIndices()
+			if len(sorted) != len(snapshots) {
+				return fmt.Errorf("subset %d: prune result invariants: sorted indices: length %d != input length %d", subset, len(sorted), len(snapshots))
+			}
+			for i, at := range sorted {
+				if i != 0 && snapshots[sorted[i-1]].After(snapshots[at]) {
+					return fmt.Errorf("subset %d: prune result invariants: sorted indices: not sorted by time", subset)
+				}
+				if i != 0 && snapshots[sorted[i-1]].Equal(snapshots[at]) && sorted[i-1] > at {
+					return fmt.Errorf("subset %d: prune result invariants: sorted indices: identical timestamps not ordered by input index", subset)
+				}
+				if rank := r.Rank(at); rank != len(sorted)-1-i {
+					return fmt.Errorf("subset %d: prune result invariants: rank: snapshot %d has rank %d, expected %d", subset, at, rank, len(sorted)-1-i)
+				}
+			}
+		}
+
 		/**
 		 * Prune "need" output will contain the number of additional snapshots
 		 * required to fulfill the policy for each period.
@@ -202,7 +664,7 @@ func pruneCorrectness(snapshots []time.Time, policy Policy, loc *time.Location)
 		}
 
 		/**
-		 * Pruning is reproducible.
+		 * Pruning is reproducible (and Prune is the same as PruneResult).
 		 */
 		rKeep, rNeed := Prune(snapshots, policy, loc)
 		if !maps.Equal(rNeed.count, need.count) {
@@ -251,24 +713,32 @@ func pruneCorrectness(snapshots []time.Time, policy Policy, loc *time.Location)
 		 * increment due to a period using that unit, even if the intervals are
 		 * different (i.e., no more than one yearly snapshot per calendar year
 		 * retained due to any yearly rule; same for monthly/calendar month,
-		 * daily/calendar day, secondly/second).
+		 * weekly/calendar week, daily/calendar day, secondly/second).
 		 */
 		{
 			inc := map[string][]int{}
 			for at, reason := range keep {
 				for _, period := range reason {
 					var key string
+					t := snapshots[at].Truncate(-1).Add(period.Slack)
 					switch period.Unit {
 					case Last:
 						continue
 					case Secondly:
-						key = period.Unit.String() + " " + strconv.FormatInt(snapshots[at].Truncate(-1).Unix(), 10)
+						key = period.Unit.String() + " " + strconv.FormatInt(t.Unix(), 10)
+					case Minutely:
+						key = period.Unit.String() + " " + strconv.FormatInt(floorDiv(t.Unix(), 60), 10)
 					case Daily:
-						key = period.Unit.String() + " " + snapshots[at].Truncate(-1).Format("2006-01-02")
+						key = period.Unit.String() + " " + t.Format("2006-01-02")
+					case Weekly:
+						year, week := t.ISOWeek()
+						key = period.Unit.String() + " " + strconv.Itoa(year) + "-W" + strconv.Itoa(week)
 					case Monthly:
-						key = period.Unit.String() + " " + snapshots[at].Truncate(-1).Format("2006-01")
+						key = period.Unit.String() + " " + t.Format("2006-01")
+					case Quarterly:
+						key = period.Unit.String() + " " + t.Format("2006") + "-Q" + strconv.Itoa(int(t.Month()+2)/3)
 					case Yearly:
-						key = period.Unit.String() + " " + snapshots[at].Truncate(-1).Format("2006")
+						key = period.Unit.String() + " " + t.Format("2006")
 					default:
 						panic("wtf")
 					}
@@ -366,7 +836,7 @@ func TestPrune(t *testing.T) {
 	){
 		func() (times []time.Time, policy Policy, output string) {
 			for i := 0; i < 5000*24*2; i++ {
-				times = append(times, time.Date(2000, 1, 1, 0, 30*i, prand(30*60, i, 0xABCDEF0123456789), 0, time.UTC))
+				times = append(times, time.Date(2000, 1, 1, 0, 30*i, int(prand(30*60, int64(i), 0xABCDEF0123456789)), 0, time.UTC))
 			}
 
 			policy.MustSet(Yearly, 5, -1)
@@ -410,27 +880,10 @@ func TestPrune(t *testing.T) {
 			t.Run("Output", func(t *testing.T) {
 				keep, need := Prune(times, policy, times[0].Location())
 
-				var b bytes.Buffer
-				for at, reason := range keep {
-					at := times[at]
-					if len(reason) != 0 {
-						b.WriteString(at.Format(time.ANSIC))
-						b.WriteString(" | ")
-						for i, r := range reason {
-							if i != 0 {
-								b.WriteString(", ")
-							}
-							b.WriteString(r.String())
-						}
-						b.WriteString("\n")
-					}
-				}
-				b.WriteString(need.String())
-				b.WriteString("\n")
-
-				t.Log("\n" + b.String())
+				b := pruneOutput(times, keep, need)
+				t.Log("\n" + string(b))
 
-				hash := sha256.Sum256(b.Bytes())
+				hash := sha256.Sum256(b)
 				actual := hex.EncodeToString(hash[:])
 				if actual != output {
 					t.Errorf("incorrect output hash %q", actual)
@@ -438,6 +891,9 @@ func TestPrune(t *testing.T) {
 			})
 
 			t.Run("Correctness", func(t *testing.T) {
+				if testing.Short() {
+					t.Skip("skipping slow correctness checks in short mode")
+				}
 				for _, loc := range locs {
 					loc := loc
 					t.Run(loc.String(), func(t *testing.T) {
@@ -455,45 +911,1111 @@ func TestPrune(t *testing.T) {
 	}
 }
 
-// TODO: fuzz it (generating a random policy, and a seed for generating 1000
-// random time intervals), checking the guarantees for Prune (and ensuring it
-// works adding the times one at a time).
-
-func ExamplePrune() {
-	var times []time.Time
-	for i := 0; i < 5000*24*2; i++ {
-		times = append(times, time.Date(2000, 1, 1, 0, 30*i, prand(30*60, i, 0xABCDEF0123456789), 0, time.UTC))
+// TestPruneCorpus runs the regression cases in testdata/prune, each of which is
+// a txtar archive containing a policy, a timezone, a list of RFC 3339
+// snapshots, and the hash of the output.
+func TestPruneCorpus(t *testing.T) {
+	ds, err := os.ReadDir(filepath.Join("testdata", "prune"))
+	if err != nil {
+		t.Fatal(err)
 	}
+	for _, d := range ds {
+		name := d.Name()
 
-	var policy Policy
-	policy.MustSet(Yearly, 5, -1)
-	policy.MustSet(Yearly, 2, 10)
-	policy.MustSet(Yearly, 1, 3)
-	policy.MustSet(Monthly, 6, 4)
-	policy.MustSet(Monthly, 2, 6)
-	policy.MustSet(Daily, 1, 7)
-	policy.MustSet(Secondly, int(time.Hour/time.Second), 6)
-	policy.MustSet(Last, 1, 3)
-	fmt.Println(policy)
+		arc, err := txtar.ParseFile(filepath.Join("testdata", "prune", name))
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	keep, need := Prune(times, policy, time.UTC)
-	for at, reason := range keep {
-		at := times[at]
-		if len(reason) != 0 {
-			var b strings.Builder
-			for i, r := range reason {
-				if i != 0 {
-					b.WriteString(", ")
+		t.Run(strings.TrimSuffix(name, ".txt"), func(t *testing.T) {
+			var (
+				policy Policy
+				loc    = time.UTC
+				times  []time.Time
+				output string
+			)
+			for _, f := range arc.Files {
+				switch f.Name {
+				case "policy":
+					if err := policy.UnmarshalText(f.Data); err != nil {
+						t.Fatalf("parse policy: %v", err)
+					}
+				case "timezone":
+					if loc, err = time.LoadLocation(strings.TrimSpace(string(f.Data))); err != nil {
+						t.Fatalf("load timezone: %v", err)
+					}
+				case "snapshots":
+					for _, line := range strings.Fields(string(f.Data)) {
+						v, err := time.Parse(time.RFC3339, line)
+						if err != nil {
+							t.Fatalf("parse snapshot: %v", err)
+						}
+						times = append(times, v.In(loc))
+					}
+				case "hash":
+					output = strings.TrimSpace(string(f.Data))
 				}
-				b.WriteString(r.String())
 			}
-			fmt.Println(at.Format(time.ANSIC), "|", b.String())
-		}
+
+			keep, need := Prune(times, policy, loc)
+
+			b := pruneOutput(times, keep, need)
+			t.Log("\n" + string(b))
+
+			hash := sha256.Sum256(b)
+			if actual := hex.EncodeToString(hash[:]); actual != output {
+				t.Errorf("incorrect output hash %q", actual)
+			}
+			if err := pruneCorrectness(times, policy, loc); err != nil {
+				t.Error(err.Error())
+			}
+		})
 	}
-	fmt.Println(need)
+}
 
-	// Output:
-	// last (3), 1h time (6), 1 day (7), 2 month (6), 6 month (4), 1 year (3), 2 year (10), 5 year (inf)
+func TestPruneTies(t *testing.T) {
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	policy.MustSet(Last, 1, 2)
+	policy.MustSet(Daily, 1, -1)
+	for i := 0; i < 4; i++ {
+		times = append(times, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	for _, tc := range []struct {
+		reverse  bool
+		ordinals []int64
+		last     []int
+		daily    int
+	}{
+		{false, nil, []int{2, 3}, 0},
+		{true, nil, []int{0, 1}, 3},
+		{false, []int64{7, 5, 8, 6}, []int{0, 2}, 1},
+		{true, []int64{7, 5, 8, 6}, []int{0, 2}, 1},
+		{false, []int64{1, 1, 2, 2}, []int{2, 3}, 0},
+		{true, []int64{1, 1, 2, 2}, []int{2, 3}, 1},
+	} {
+		for i := 0; i < 10; i++ {
+			r := PruneResult(times, policy, time.UTC, &PruneOptions{ReverseTies: tc.reverse, Ordinals: tc.ordinals})
+			for at, reason := range r.Reasons {
+				if exp := slices.Contains(tc.last, at); exp != slices.Contains(reason, Period{Unit: Last, Interval: 1}) {
+					t.Fatalf("reverse=%t: snapshot %d: expected last=%t, got reasons %v", tc.reverse, at, exp, reason)
+				}
+				if exp := at == tc.daily; exp != slices.Contains(reason, Period{Unit: Daily, Interval: 1}) {
+					t.Fatalf("reverse=%t: snapshot %d: expected daily=%t, got reasons %v", tc.reverse, at, exp, reason)
+				}
+			}
+		}
+	}
+
+	func() {
+		defer func() {
+			if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "Ordinals") {
+				t.Errorf("expected panic for mismatched ordinals, got %v", err)
+			}
+		}()
+		PruneResult(times, policy, time.UTC, &PruneOptions{Ordinals: []int64{1, 2, 3}})
+	}()
+}
+
+func TestPruneLastInterval(t *testing.T) {
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	policy.MustSet(Last, 1, 2)
+	policy.MustSet(Last, 5, 3)
+	for i := 0; i < 20; i++ {
+		times = append(times, time.Date(2000, 1, 1, 0, 0, i, 0, time.UTC))
+	}
+	r := PruneResult(times, policy, time.UTC, nil)
+
+	var kept []int
+	for at, reason := range r.Reasons {
+		if slices.Contains(reason, Period{Unit: Last, Interval: 5}) {
+			kept = append(kept, at)
+		}
+	}
+	if exp := []int{9, 14, 19}; !slices.Equal(kept, exp) {
+		t.Errorf("expected every 5th snapshot %v to be kept, got %v", exp, kept)
+	}
+	if n := r.Need.Missing(Period{Unit: Last, Interval: 5}); n != 0 {
+		t.Errorf("expected no more snapshots to be needed, got %d", n)
+	}
+}
+
+func TestPruneMinutely(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kathmandu") // UTC+05:45
+	if err != nil {
+		panic(err)
+	}
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	policy.MustSet(Minutely, 5, -1)
+	for i := 0; i < 180; i++ {
+		times = append(times, time.Date(2024, 1, 1, 0, 0, i*20+7, 0, loc))
+	}
+	r := PruneResult(times, policy, loc, nil)
+
+	var kept []time.Time
+	for at, reason := range r.Reasons {
+		if len(reason) != 0 {
+			kept = append(kept, times[at])
+		}
+	}
+	if len(kept) != 12 {
+		t.Errorf("expected 12 snapshots to be kept, got %d", len(kept))
+	}
+	for _, at := range kept {
+		if at.Minute()%5 != 0 || at.Second() != 7 {
+			t.Errorf("expected kept snapshot %s to be at the start of a 5-minute clock interval", at)
+		}
+	}
+}
+
+func TestPruneWithin(t *testing.T) {
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	if !policy.SetWithin(3*time.Hour + time.Second) {
+		t.Fatalf("failed to set within")
+	}
+	if policy.SetWithin(time.Millisecond) {
+		t.Errorf("expected within less than a second to be invalid")
+	}
+	for i := 0; i < 10; i++ {
+		times = append(times, time.Date(2000, 1, 1, i, 0, 0, 0, time.UTC))
+	}
+	for _, tc := range []struct {
+		now  time.Time
+		kept []int
+	}{
+		{time.Time{}, []int{6, 7, 8, 9}}, // relative to the newest snapshot
+		{time.Date(2000, 1, 1, 5, 30, 0, 0, time.UTC), []int{3, 4, 5, 6, 7, 8, 9}},
+		{time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), nil},
+	} {
+		r := PruneResult(times, policy, time.UTC, &PruneOptions{Now: tc.now})
+
+		var kept []int
+		for at, reason := range r.Reasons {
+			if len(reason) != 0 {
+				kept = append(kept, at)
+			}
+		}
+		if !slices.Equal(kept, tc.kept) {
+			t.Errorf("now %s: expected %v to be kept, got %v", tc.now, tc.kept, kept)
+		}
+	}
+
+	clock := fixedClock(time.Date(2000, 1, 1, 5, 30, 0, 0, time.UTC))
+	if act := PruneResult(times, policy, time.UTC, &PruneOptions{Clock: clock}).Kept(); !slices.Equal(act, []int{3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("clock: expected [3 4 5 6 7 8 9] to be kept, got %v", act)
+	}
+	if act := PruneResult(times, policy, time.UTC, &PruneOptions{Clock: clock, Now: times[9]}).Kept(); !slices.Equal(act, []int{6, 7, 8, 9}) {
+		t.Errorf("clock with now: expected [6 7 8 9] to be kept, got %v", act)
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestSetZoneInfo(t *testing.T) {
+	// TZif v1 with a single +05:30 type and no transitions
+	var tzif []byte
+	tzif = append(tzif, "TZif"...)
+	tzif = append(tzif, make([]byte, 16)...)
+	for _, n := range []uint32{0, 0, 0, 0, 1, 4} { // isutcnt isstdcnt leapcnt timecnt typecnt charcnt
+		tzif = binary.BigEndian.AppendUint32(tzif, n)
+	}
+	tzif = binary.BigEndian.AppendUint32(tzif, 5*3600+30*60)
+	tzif = append(tzif, 0, 0)
+	tzif = append(tzif, "TST\x00"...)
+
+	SetZoneInfo(fstest.MapFS{
+		"Test/Zone": {Data: tzif},
+	})
+	defer SetZoneInfo(nil)
+
+	loc, err := LoadLocation("Test/Zone")
+	if err != nil {
+		t.Fatalf("load test zone: %v", err)
+	}
+	if name, offset := time.Date(2000, 1, 1, 0, 0, 0, 0, loc).Zone(); name != "TST" || offset != 5*3600+30*60 {
+		t.Errorf("load test zone: expected TST +05:30, got %s %d", name, offset)
+	}
+	if _, err := LoadLocation("America/Toronto"); err != nil {
+		t.Errorf("load system zone: unexpected error: %v", err)
+	}
+	if _, err := ParsePeriod("daily/Test/Zone"); err != nil {
+		t.Errorf("parse period with test zone: unexpected error: %v", err)
+	}
+
+	SetZoneInfo(nil)
+	if _, err := LoadLocation("Test/Zone"); err == nil {
+		t.Errorf("load test zone after reset: expected error")
+	}
+}
+
+func TestPruneOrdinal(t *testing.T) {
+	policy, err := ParsePolicy("3@last", "ordinal:100")
+	if err != nil {
+		panic(err)
+	}
+	if !policy.IsOrdinal() {
+		t.Errorf("expected policy to be ordinal")
+	}
+	if p, _ := ParsePolicy("3@last", "daily"); p.IsOrdinal() {
+		t.Errorf("expected policy with daily rules to not be ordinal")
+	}
+
+	var values []int64
+	for i := int64(550); i > 0; i -= 3 {
+		values = append(values, i)
+	}
+	r := PruneOrdinal(values, policy, nil)
+
+	var kept []int64
+	for at, reason := range r.Reasons {
+		if len(reason) != 0 {
+			kept = append(kept, values[at])
+		}
+	}
+	if exp := []int64{550, 547, 544, 502, 400, 301, 202, 100, 1}; !slices.Equal(kept, exp) {
+		t.Errorf("expected %v to be kept, got %v", exp, kept)
+	}
+}
+
+func TestPruneSeq(t *testing.T) {
+	// irregular snapshots every few hours for two years, with some duplicates
+	var snapshots []time.Time
+	for t := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC); t.Year() < 2024; t = t.Add(time.Duration(len(snapshots)%7) * time.Hour) {
+		snapshots = append(snapshots, t)
+	}
+	seq := func(yield func(int, time.Time) bool) {
+		for i, t := range snapshots {
+			if !yield(i, t) {
+				return
+			}
+		}
+	}
+	for _, rules := range [][]string{
+		{"1@last"},
+		{"5@last:3"},
+		{"last:4"},
+		{"3@within:48h"},
+		{"within:24h"},
+		{"7@daily", "4@weekly", "12@monthly", "yearly"},
+		{"10@secondly:6h", "daily:3", "3@monthly:2"},
+		{"3@last", "within:72h", "10@daily", "monthly"},
+		{"100@yearly", "1000@daily"},
+	} {
+		policy, err := ParsePolicy(rules...)
+		if err != nil {
+			panic(err)
+		}
+		exp := PruneResult(snapshots, policy, time.UTC, nil)
+
+		act, need, err := PruneSeq(seq, policy, time.UTC)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", policy, err)
+			continue
+		}
+		for i, reasons := range exp.Reasons {
+			if !slices.Equal(act[i], reasons) {
+				t.Errorf("%s: snapshot %d: expected %v, got %v", policy, i, reasons, act[i])
+			}
+		}
+		if len(act) != len(exp.Kept()) {
+			t.Errorf("%s: expected %d kept snapshots, got %d", policy, len(exp.Kept()), len(act))
+		}
+		if !need.Policy().Equal(exp.Need.Policy()) {
+			t.Errorf("%s: expected need %s, got %s", policy, exp.Need, need)
+		}
+	}
+
+	var policy Policy
+	policy.MustSet(Last, 1, 1)
+	if _, _, err := PruneSeq(func(yield func(int, time.Time) bool) {
+		_ = yield(0, time.Unix(100, 0)) && yield(1, time.Unix(50, 0))
+	}, policy, time.UTC); err == nil {
+		t.Errorf("expected error for unsorted snapshots")
+	}
+}
+
+func TestPruneAt(t *testing.T) {
+	policy, err := ParsePolicy("1@last", "within:2h")
+	if err != nil {
+		panic(err)
+	}
+	now := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		now.Add(-3 * time.Hour),
+		now.Add(-time.Hour),
+		now.Add(5 * time.Hour), // future
+	}
+	keep, _ := PruneAt(now, times, policy, time.UTC)
+	for at, exp := range []bool{false, true, true} {
+		if act := len(keep[at]) != 0; act != exp {
+			t.Errorf("snapshot %d: expected keep=%t, got %t", at, exp, act)
+		}
+	}
+	keep, _ = Prune(times, policy, time.UTC)
+	for at, exp := range []bool{false, false, true} {
+		if act := len(keep[at]) != 0; act != exp {
+			t.Errorf("snapshot %d: relative to the newest: expected keep=%t, got %t", at, exp, act)
+		}
+	}
+}
+
+func TestPrunePreferred(t *testing.T) {
+	var times []time.Time
+	for d := 1; d <= 4; d++ {
+		for h := 0; h < 24; h += 6 {
+			times = append(times, time.Date(2000, 1, d, h, 0, 0, 0, time.UTC))
+		}
+	}
+	times = times[:len(times)-1]
+	before, err := ParsePolicy("3@daily")
+	if err != nil {
+		panic(err)
+	}
+	after, err := ParsePolicy("3@daily~7h") // now starts at 17:00 the previous day
+	if err != nil {
+		panic(err)
+	}
+	prev := PruneResult(times, before, time.UTC, nil).Kept()
+	if exp := []int{4, 8, 12}; !slices.Equal(prev, exp) {
+		t.Fatalf("before: expected kept %v, got %v", exp, prev)
+	}
+	if act, exp := PruneResult(times, after, time.UTC, nil).Kept(), []int{3, 7, 11}; !slices.Equal(act, exp) {
+		t.Errorf("after: expected kept %v, got %v", exp, act)
+	}
+	for _, mode := range []SelectMode{FirstSnapshot, LastSnapshot, ClosestSnapshot} {
+		opt := &PruneOptions{
+			SelectMode: mode,
+			Preferred:  func(i int) bool { return slices.Contains(prev, i) },
+		}
+		if act := PruneResult(times, after, time.UTC, opt).Kept(); !slices.Equal(act, prev) {
+			t.Errorf("mode %d: preferred: expected kept %v, got %v", mode, prev, act)
+		}
+		// pinned snapshots still take precedence
+		opt.Pinned = func(i int) bool { return i == 10 }
+		if act, exp := PruneResult(times, after, time.UTC, opt).Kept(), []int{4, 10, 12}; !slices.Equal(act, exp) {
+			t.Errorf("mode %d: preferred and pinned: expected kept %v, got %v", mode, exp, act)
+		}
+	}
+}
+
+func TestPrunePinned(t *testing.T) {
+	policy, err := ParsePolicy("2@daily")
+	if err != nil {
+		panic(err)
+	}
+	var times []time.Time
+	for d := 1; d <= 4; d++ {
+		for h := 0; h < 24; h += 6 {
+			times = append(times, time.Date(2000, 1, d, h, 0, 0, 0, time.UTC))
+		}
+	}
+	pinned := map[int]bool{
+		2:  true, // day 1, not needed by the policy
+		13: true, // day 4, preferred over hour 0
+		14: true, // day 4, but only the first one counts
+	}
+	r := PruneResult(times, policy, time.UTC, &PruneOptions{
+		Pinned: func(i int) bool { return pinned[i] },
+	})
+	var (
+		daily = Period{Unit: Daily, Interval: 1}
+		pin   = Period{Unit: Pinned, Interval: 1}
+	)
+	for at, reasons := range r.Reasons {
+		var exp []Period
+		switch at {
+		case 2, 14:
+			exp = []Period{pin}
+		case 8:
+			exp = []Period{daily}
+		case 13:
+			exp = []Period{daily, pin}
+		}
+		if !slices.Equal(reasons, exp) {
+			t.Errorf("snapshot %d: expected %v, got %v", at, exp, reasons)
+		}
+	}
+
+	r = PruneResult(times, policy, time.UTC, &PruneOptions{
+		Protected: []TimeRange{{
+			Start: time.Date(2000, 1, 1, 6, 0, 0, 0, time.UTC),
+			End:   time.Date(2000, 1, 1, 18, 0, 0, 0, time.UTC),
+		}},
+	})
+	for at, reasons := range r.Reasons {
+		var exp []Period
+		switch at {
+		case 1, 2:
+			exp = []Period{pin}
+		case 8, 12:
+			exp = []Period{daily}
+		}
+		if !slices.Equal(reasons, exp) {
+			t.Errorf("protected: snapshot %d: expected %v, got %v", at, exp, reasons)
+		}
+	}
+
+	if pin.String() != "pinned" {
+		t.Errorf("expected pinned period to be formatted as pinned, got %q", pin.String())
+	}
+	if policy.Set(pin, 1) {
+		t.Errorf("expected pinned period to be rejected by policy")
+	}
+}
+
+func TestPruneProgress(t *testing.T) {
+	times := make([]time.Time, 100000)
+	for i := range times {
+		times[i] = time.Unix(int64(i)*60, 0)
+	}
+	policy, err := ParsePolicy("24@secondly:1h", "daily")
+	if err != nil {
+		panic(err)
+	}
+	var calls [][2]int
+	PruneResult(times, policy, time.UTC, &PruneOptions{
+		Progress: func(done, total int) {
+			calls = append(calls, [2]int{done, total})
+		},
+	})
+	if exp := [][2]int{{65536, 200000}, {131072, 200000}, {196608, 200000}, {200000, 200000}}; !slices.Equal(calls, exp) {
+		t.Errorf("expected progress %v, got %v", exp, calls)
+	}
+
+	calls = nil
+	PruneResult(nil, policy, time.UTC, &PruneOptions{
+		Progress: func(done, total int) {
+			calls = append(calls, [2]int{done, total})
+		},
+	})
+	if exp := [][2]int{{0, 0}}; !slices.Equal(calls, exp) {
+		t.Errorf("expected progress %v for no snapshots, got %v", exp, calls)
+	}
+}
+
+func TestPruneSelectMode(t *testing.T) {
+	var policy Policy
+	policy.Set(Period{Unit: Daily, Interval: 1, Slack: 10 * time.Minute}, -1)
+	times := []time.Time{
+		time.Date(1999, 12, 31, 23, 51, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 0, 2, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 23, 49, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 23, 58, 0, 0, time.UTC),
+		time.Date(2000, 1, 2, 0, 1, 0, 0, time.UTC),
+	}
+	for _, tc := range []struct {
+		mode SelectMode
+		keep []int
+	}{
+		{FirstSnapshot, []int{0, 4}},
+		{LastSnapshot, []int{3, 5}},
+		{ClosestSnapshot, []int{1, 5}},
+	} {
+		r := PruneResult(times, policy, time.UTC, &PruneOptions{SelectMode: tc.mode})
+		for at, reasons := range r.Reasons {
+			if exp, act := slices.Contains(tc.keep, at), len(reasons) != 0; exp != act {
+				t.Errorf("mode %d: snapshot %d: expected keep=%t, got %t", tc.mode, at, exp, act)
+			}
+		}
+		r = PruneResult(times, policy, time.UTC, &PruneOptions{SelectMode: tc.mode, Pinned: func(i int) bool { return i == 2 }})
+		if !slices.Contains(r.Reasons[2], Period{Unit: Daily, Interval: 1, Slack: 10 * time.Minute}) {
+			t.Errorf("mode %d: expected pinned snapshot to be preferred", tc.mode)
+		}
+	}
+
+	// snapshots taken just before the start of an interval can be closest
+	policy = Policy{}
+	policy.MustSet(Monthly, 1, -1)
+	times = []time.Time{
+		time.Date(1999, 12, 31, 23, 59, 0, 0, time.UTC),
+		time.Date(2000, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 31, 23, 0, 0, 0, time.UTC),
+		time.Date(2000, 2, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(2000, 2, 20, 0, 0, 0, 0, time.UTC),
+	}
+	r := PruneResult(times, policy, time.UTC, &PruneOptions{SelectMode: ClosestSnapshot})
+	for at, exp := range []bool{true, false, true, false, true} {
+		if act := len(r.Reasons[at]) != 0; act != exp {
+			t.Errorf("closest monthly: snapshot %d: expected keep=%t, got %t", at, exp, act)
+		}
+	}
+}
+
+func TestPruneMonthMode(t *testing.T) {
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	policy.MustSet(Monthly, 1, -1)
+	for i := 0; i < 365; i++ {
+		times = append(times, time.Date(2023, 1, 1+i, 12, 0, 0, 0, time.UTC))
+	}
+	for _, tc := range []struct {
+		mode MonthMode
+		n    int
+	}{
+		{CalendarMonth, 12},
+		{FixedMonth, 13},
+	} {
+		r := PruneResult(times, policy, time.UTC, &PruneOptions{MonthMode: tc.mode})
+
+		var kept []time.Time
+		for at, reason := range r.Reasons {
+			if len(reason) != 0 {
+				kept = append(kept, times[at])
+			}
+		}
+		if len(kept) != tc.n {
+			t.Errorf("mode %d: expected %d snapshots to be kept, got %d", tc.mode, tc.n, len(kept))
+		}
+		for i, at := range kept {
+			switch tc.mode {
+			case CalendarMonth:
+				if at.Day() != 1 {
+					t.Errorf("mode %d: expected kept snapshot %s to be on the first day of the month", tc.mode, at)
+				}
+			case FixedMonth:
+				if d := epochDay(at) % 30; i != 0 && d != 0 {
+					t.Errorf("mode %d: expected kept snapshot %s to be at the start of a 30-day window, got day %d", tc.mode, at, d)
+				}
+			}
+		}
+	}
+}
+
+func TestPruneWeekMode(t *testing.T) {
+	var (
+		policy Policy
+		times  []time.Time
+	)
+	policy.MustSet(Weekly, 1, -1)
+	for i := 0; i < 365; i++ {
+		times = append(times, time.Date(2023, 1, 1+i, 12, 0, 0, 0, time.UTC))
+	}
+	for _, tc := range []struct {
+		mode WeekMode
+		day  time.Weekday
+		n    int
+	}{
+		{MondayWeek, time.Monday, 53}, // 2023-01-01 is a Sunday
+		{SundayWeek, time.Sunday, 53},
+	} {
+		r := PruneResult(times, policy, time.UTC, &PruneOptions{WeekMode: tc.mode})
+
+		var kept []time.Time
+		for at, reason := range r.Reasons {
+			if len(reason) != 0 {
+				kept = append(kept, times[at])
+			}
+		}
+		if len(kept) != tc.n {
+			t.Errorf("mode %d: expected %d snapshots to be kept, got %d", tc.mode, tc.n, len(kept))
+		}
+		for i, at := range kept {
+			if i != 0 && at.Weekday() != tc.day {
+				t.Errorf("mode %d: expected kept snapshot %s to be on a %s", tc.mode, at, tc.day)
+			}
+		}
+	}
+}
+
+func TestPruneFiscalYear(t *testing.T) {
+	var policy Policy
+	policy.MustSet(Quarterly, 1, -1)
+	policy.MustSet(Quarterly, 4, -1)
+
+	var times []time.Time
+	for i := 0; i < 24; i++ {
+		times = append(times, time.Date(2023, time.Month(1+i), 15, 0, 0, 0, 0, time.UTC))
+	}
+	for _, tc := range []struct {
+		start time.Month
+		q, y  []int // months (1-24) kept
+	}{
+		{0, []int{1, 4, 7, 10, 13, 16, 19, 22}, []int{1, 13}},
+		{time.April, []int{1, 4, 7, 10, 13, 16, 19, 22}, []int{1, 4, 16}},
+		{time.February, []int{1, 2, 5, 8, 11, 14, 17, 20, 23}, []int{1, 2, 14}},
+	} {
+		r := PruneResult(times, policy, time.UTC, &PruneOptions{FiscalYearStart: tc.start})
+
+		var q, y []int
+		for at, reason := range r.Reasons {
+			for _, period := range reason {
+				switch period.Interval {
+				case 1:
+					q = append(q, at+1)
+				case 4:
+					y = append(y, at+1)
+				}
+			}
+		}
+		if !slices.Equal(q, tc.q) {
+			t.Errorf("start %d: expected quarterly snapshots %v, got %v", tc.start, tc.q, q)
+		}
+		if !slices.Equal(y, tc.y) {
+			t.Errorf("start %d: expected yearly snapshots %v, got %v", tc.start, tc.y, y)
+		}
+	}
+}
+
+func TestResultPhases(t *testing.T) {
+	var policy Policy
+	policy.MustSet(Last, 1, 2)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{now.Add(-4 * time.Hour), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)}
+
+	// first run: everything prunable is expiring
+	phases, since := PruneResult(times, policy, time.UTC, nil).Phases(nil, now, time.Hour)
+	if !slices.Equal(phases, []Phase{PhaseExpiring, PhaseExpiring, PhaseKept, PhaseKept}) {
+		t.Errorf("first run: incorrect phases %v", phases)
+	}
+	if !since[0].Equal(now) || !since[2].IsZero() {
+		t.Errorf("first run: incorrect since %v", since)
+	}
+
+	// second run after the grace period, with the newest snapshot removed so
+	// the second one is required again
+	now = now.Add(time.Hour)
+	phases, since = PruneResult(times[:3], policy, time.UTC, nil).Phases(since[:3], now, time.Hour)
+	if !slices.Equal(phases, []Phase{PhaseExpired, PhaseKept, PhaseKept}) {
+		t.Errorf("second run: incorrect phases %v", phases)
+	}
+	if !since[1].IsZero() {
+		t.Errorf("second run: expected since to be reset for kept snapshot, got %v", since[1])
+	}
+}
+
+func TestPruneZone(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		panic(err)
+	}
+	policy, err := ParsePolicy("daily", "yearly/UTC")
+	if err != nil {
+		panic(err)
+	}
+
+	// 2023-12-31 18:00 and 21:00 in Toronto are 23:00 and 02:00 (the next year) in UTC
+	times := []time.Time{
+		time.Date(2023, 12, 31, 18, 0, 0, 0, toronto),
+		time.Date(2023, 12, 31, 21, 0, 0, 0, toronto),
+	}
+	result := PruneResult(times, policy, toronto, nil)
+	for i, exp := range [][]Period{
+		{{Unit: Daily, Interval: 1}, {Unit: Yearly, Interval: 1, Zone: "UTC"}},
+		{{Unit: Yearly, Interval: 1, Zone: "UTC"}},
+	} {
+		if act := result.Reasons[i]; !slices.Equal(act, exp) {
+			t.Errorf("snapshot %d: expected reasons %v, got %v", i, exp, act)
+		}
+	}
+}
+
+func TestResultSummarize(t *testing.T) {
+	var policy Policy
+	policy.MustSet(Last, 1, 2)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{now.Add(-time.Hour), now.Add(-4 * time.Hour), now, now.Add(-3 * time.Hour)}
+
+	s := PruneResult(times, policy, time.UTC, nil).Summarize(times)
+	if s.Kept != 2 || s.Pruned != 2 {
+		t.Errorf("incorrect counts %d/%d", s.Kept, s.Pruned)
+	}
+	if !s.OldestKept.Equal(times[0]) || !s.NewestKept.Equal(times[2]) || s.Span() != time.Hour {
+		t.Errorf("incorrect kept range %s to %s", s.OldestKept, s.NewestKept)
+	}
+	if !s.OldestPruned.Equal(times[1]) || !s.NewestPruned.Equal(times[3]) {
+		t.Errorf("incorrect pruned range %s to %s", s.OldestPruned, s.NewestPruned)
+	}
+
+	// results constructed by the caller (e.g., combined from groups)
+	r := Result{Reasons: PruneResult(times, policy, time.UTC, nil).Reasons}
+	if s2 := r.Summarize(times); s2 != s {
+		t.Errorf("expected summary %+v for constructed result, got %+v", s, s2)
+	}
+}
+
+func TestResultKept(t *testing.T) {
+	var policy Policy
+	policy.MustSet(Last, 1, 2)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{now.Add(-time.Hour), now.Add(-4 * time.Hour), now, now.Add(-3 * time.Hour)}
+
+	r := PruneResult(times, policy, time.UTC, nil)
+	if act, exp := r.Kept(), []int{0, 2}; !slices.Equal(act, exp) {
+		t.Errorf("expected kept %v, got %v", exp, act)
+	}
+	if act, exp := r.Pruned(), []int{1, 3}; !slices.Equal(act, exp) {
+		t.Errorf("expected pruned %v, got %v", exp, act)
+	}
+	if act, exp := r.KeptTimes(times), []time.Time{times[0], times[2]}; !slices.Equal(act, exp) {
+		t.Errorf("expected kept times %v, got %v", exp, act)
+	}
+	if act, exp := r.PrunedTimes(times), []time.Time{times[1], times[3]}; !slices.Equal(act, exp) {
+		t.Errorf("expected pruned times %v, got %v", exp, act)
+	}
+}
+
+func TestResultRank(t *testing.T) {
+	var policy Policy
+	policy.MustSet(Last, 1, 2)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{now.Add(-time.Hour), now.Add(-4 * time.Hour), now, now.Add(-3 * time.Hour)}
+
+	r := PruneResult(times, policy, time.UTC, nil)
+	for i, exp := range []int{1, 3, 0, 2, -1} {
+		if act := r.Rank(i); act != exp {
+			t.Errorf("expected rank %d for snapshot %d, got %d", exp, i, act)
+		}
+	}
+	if act := r.Rank(-1); act != -1 {
+		t.Errorf("expected rank -1 for an out of range index, got %d", act)
+	}
+	if act := (Result{Reasons: r.Reasons}).Rank(0); act != -1 {
+		t.Errorf("expected rank -1 for a constructed result, got %d", act)
+	}
+}
+
+func TestResultHash(t *testing.T) {
+	policy, err := ParsePolicy("2@last", "3@daily", "monthly")
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{now.Add(-time.Hour), now.Add(-48 * time.Hour), now, now.Add(-2 * time.Hour)}
+
+	r := PruneResult(times, policy, time.UTC, nil)
+	sum := sha256.Sum256([]byte("last\n" +
+		"daily,monthly\n" +
+		"last\n" +
+		"daily,monthly\n" +
+		"need 0@last\n" +
+		"need 1@daily\n"))
+	if act, exp := r.Hash(), hex.EncodeToString(sum[:]); act != exp {
+		t.Errorf("expected hash %s, got %s", exp, act)
+	}
+
+	for _, why := range r.Reasons {
+		slices.Reverse(why)
+	}
+	if act, exp := r.Hash(), hex.EncodeToString(sum[:]); act != exp {
+		t.Errorf("expected hash %s regardless of the order of reasons, got %s", exp, act)
+	}
+}
+
+func TestResultNeed(t *testing.T) {
+	policy, err := ParsePolicy("3@last", "2@daily", "daily:2")
+	if err != nil {
+		panic(err)
+	}
+	times := []time.Time{
+		time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	r := PruneResult(times, policy, time.UTC, nil)
+	for _, tc := range []struct {
+		period  Period
+		missing int
+	}{
+		{Period{Unit: Last, Interval: 1}, 1},
+		{Period{Unit: Daily, Interval: 1}, 1},
+		{Period{Unit: Daily, Interval: 2}, -1},
+		{Period{Unit: Yearly, Interval: 1}, 0},
+	} {
+		if act := r.Need.Missing(tc.period); act != tc.missing {
+			t.Errorf("%s: expected %d missing, got %d", tc.period, tc.missing, act)
+		}
+	}
+	if r.Need.Satisfied() {
+		t.Errorf("expected need to be unsatisfied")
+	}
+	var n int
+	r.Need.Each(func(period Period, missing int) {
+		if missing != r.Need.Missing(period) {
+			t.Errorf("%s: incorrect missing count %d", period, missing)
+		}
+		n++
+	})
+	if n != 3 {
+		t.Errorf("expected 3 periods, got %d", n)
+	}
+	if act, exp := r.Need.String(), r.Need.Policy().String(); act != exp {
+		t.Errorf("expected string %q, got %q", exp, act)
+	}
+
+	policy.Set(Period{Unit: Last, Interval: 1}, 2)
+	policy.Set(Period{Unit: Daily, Interval: 1}, 1)
+	if r := PruneResult(times, policy, time.UTC, nil); !r.Need.Satisfied() {
+		t.Errorf("expected need to be satisfied, got %s", r.Need)
+	}
+}
+
+func TestResultSpans(t *testing.T) {
+	policy, err := ParsePolicy("1@last", "5@daily", "3@monthly")
+	if err != nil {
+		panic(err)
+	}
+	times := []time.Time{
+		time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC),
+	}
+	spans := PruneResult(times, policy, time.UTC, nil).Spans(times, policy, time.UTC, nil)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	// the 5 snapshots span 17 days due to the gaps
+	if s := spans[0]; s.Kept != 5 || !s.Oldest.Equal(times[0]) || !s.Newest.Equal(times[4]) || s.Intervals != 17 {
+		t.Errorf("incorrect daily span %+v", s)
+	}
+	// only 2 months of history
+	if s := spans[1]; s.Kept != 2 || !s.Oldest.Equal(times[0]) || !s.Newest.Equal(times[1]) || s.Intervals != 2 {
+		t.Errorf("incorrect monthly span %+v", s)
+	}
+}
+
+func TestPolicyMerge(t *testing.T) {
+	a, err := ParsePolicy("3@last", "7@daily", "4@weekly", "yearly")
+	if err != nil {
+		panic(err)
+	}
+	b, err := ParsePolicy("14@daily", "4@weekly", "12@monthly", "2@yearly")
+	if err != nil {
+		panic(err)
+	}
+	for _, tc := range []struct {
+		strategy MergeStrategy
+		exp      string
+	}{
+		{MergeMax, "3@last 14@daily 4@weekly 12@monthly yearly"},
+		{MergeSum, "3@last 21@daily 8@weekly 12@monthly yearly"},
+		{MergeError, ""},
+	} {
+		m, err := a.Merge(b, tc.strategy)
+		if tc.exp == "" {
+			if err == nil {
+				t.Errorf("strategy %d: expected error", tc.strategy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("strategy %d: unexpected error: %v", tc.strategy, err)
+			continue
+		}
+		if act, _ := m.MarshalText(); string(act) != tc.exp {
+			t.Errorf("strategy %d: expected %q, got %q", tc.strategy, tc.exp, act)
+		}
+	}
+	if m, err := a.Merge(a, MergeError); err != nil {
+		t.Errorf("merge identical: unexpected error: %v", err)
+	} else if act, exp := m.String(), a.String(); act != exp {
+		t.Errorf("merge identical: expected %q, got %q", exp, act)
+	}
+	if act, _ := a.MarshalText(); string(act) != "3@last 7@daily 4@weekly yearly" {
+		t.Errorf("merge modified the original policy: %q", act)
+	}
+}
+
+func TestPolicyDiff(t *testing.T) {
+	a, err := ParsePolicy("3@last", "7@daily", "4@weekly", "yearly")
+	if err != nil {
+		panic(err)
+	}
+	b, err := ParsePolicy("3@last", "14@daily", "12@monthly", "yearly")
+	if err != nil {
+		panic(err)
+	}
+	var act []string
+	for _, c := range a.Diff(b) {
+		act = append(act, c.String())
+	}
+	if exp := []string{"7@daily -> 14@daily", "-4@weekly", "+12@monthly"}; !slices.Equal(act, exp) {
+		t.Errorf("expected diff %q, got %q", exp, act)
+	}
+	if a.Equal(b) || b.Equal(a) {
+		t.Errorf("expected policies to be different")
+	}
+	if !a.Equal(a.Clone()) || len(a.Diff(a.Clone())) != 0 {
+		t.Errorf("expected policy to equal its clone")
+	}
+	if !(Policy{}).Equal(Policy{count: map[Period]int{}}) {
+		t.Errorf("expected empty policies to be equal")
+	}
+}
+
+func TestPruneSubsecond(t *testing.T) {
+	// checkpoints every 100ms for 3s, with jitter
+	var snapshots []time.Time
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		snapshots = append(snapshots, base.Add(time.Duration(i)*100*time.Millisecond+time.Duration(i%3)*time.Millisecond))
+	}
+	for rule, exp := range map[string][]int{
+		"secondly:500ms":         {0, 5, 10, 15, 20, 25},
+		"secondly:500ms+200ms":   {0, 2, 7, 12, 17, 22, 27},
+		"millisecondly:1000+250": {0, 3, 13, 23},
+		"3@secondly:1.5s":        {0, 15},
+	} {
+		policy, err := ParsePolicy(rule)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", rule, err)
+			continue
+		}
+		if act := PruneResult(snapshots, policy, time.UTC, nil).Kept(); !slices.Equal(act, exp) {
+			t.Errorf("%s: expected kept %v, got %v", rule, exp, act)
+		}
+	}
+
+	policy, err := ParsePolicy("secondly:500ms")
+	if err != nil {
+		panic(err)
+	}
+	ws := policy.Windows(snapshots[0], snapshots[len(snapshots)-1], time.UTC)
+	if len(ws) != 6 || ws[1].Start != snapshots[0].Add(500*time.Millisecond) || ws[1].End.Sub(ws[1].Start) != 500*time.Millisecond {
+		t.Errorf("unexpected windows %v", ws)
+	}
+}
+
+func TestPruneOutOfRange(t *testing.T) {
+	snapshots := []time.Time{
+		time.Unix(-1<<62, 0),
+		time.Date(-5, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(10005, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Unix(1<<62, 0),
+	}
+	for rule, exp := range map[string][]int{
+		"yearly":  {0, 3, 4},
+		"monthly": {0, 3, 4},
+		"daily":   {0, 3, 4},
+		"last":    {0, 1, 2, 3, 4, 5, 6},
+	} {
+		policy, err := ParsePolicy(rule)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", rule, err)
+			continue
+		}
+		if act := PruneResult(snapshots, policy, time.UTC, nil).Kept(); !slices.Equal(act, exp) {
+			t.Errorf("%s: expected kept %v, got %v", rule, exp, act)
+		}
+	}
+}
+
+func TestPolicyWindows(t *testing.T) {
+	policy, err := ParsePolicy("1@last", "ordinal:100", "secondly:1h+30m~1m", "minutely:90+15~1m", "daily", "daily:3+1~5m", "weekly", "weekly:2+1~5m", "monthly:2", "quarterly:3+1", "yearly:3~1h", "daily:10+3@anchor=monday", "weekly@anchor=sunday", "monthly:2@anchor=15", "quarterly@anchor=02-10", "yearly~1h@anchor=04-01", "workdaily", "workdaily~1h/UTC", "cron:30_1,2_*_*_*", "cron:0_3_*_*_sun~1h/UTC")
+	if err != nil {
+		panic(err)
+	}
+	for _, x := range []string{"UTC", "EST5EDT", "Pacific/Chatham", "America/Sao_Paulo"} {
+		loc, err := time.LoadLocation(x)
+		if err != nil {
+			panic(err)
+		}
+		from := time.Date(2016, 10, 10, 7, 13, 0, 0, loc)
+		to := time.Date(2019, 3, 2, 0, 0, 0, 0, loc)
+
+		ws := policy.Windows(from, to, loc)
+		bs := map[Period][]Window{}
+		for _, w := range ws {
+			bs[w.Period] = append(bs[w.Period], w)
+		}
+		if _, ok := bs[Period{Unit: Last}]; ok {
+			t.Errorf("%s: unexpected window for last", loc)
+		}
+		if _, ok := bs[Period{Unit: Ordinal, Interval: 100}]; ok {
+			t.Errorf("%s: unexpected window for ordinal", loc)
+		}
+		for period, ws := range bs {
+			if ws[0].Start.After(from) || ws[len(ws)-1].End.Before(to) {
+				t.Errorf("%s: %s: windows %s to %s do not cover the range", loc, period, ws[0].Start, ws[len(ws)-1].End)
+			}
+			for i, w := range ws {
+				if i != 0 && !ws[i-1].End.Equal(w.Start) {
+					t.Errorf("%s: %s: windows are not contiguous at %s", loc, period, w.Start)
+				}
+				if !w.Start.Before(w.End) {
+					t.Errorf("%s: %s: window is empty at %s", loc, period, w.Start)
+				}
+				// the window must be exactly one bucket as used by Prune
+				b := period.bucket(w.Start, loc, new(PruneOptions))
+				if x := period.bucket(w.End.Add(-time.Nanosecond), loc, new(PruneOptions)); x != b {
+					t.Errorf("%s: %s: window %s to %s ends in bucket %d, expected %d", loc, period, w.Start, w.End, x, b)
+				}
+				if x := period.bucket(w.Start.Add(-time.Nanosecond), loc, new(PruneOptions)); x == b {
+					t.Errorf("%s: %s: window %s to %s starts too late", loc, period, w.Start, w.End)
+				}
+			}
+		}
+		if n := len(bs[Period{Unit: Daily, Interval: 1}]); n != 873 {
+			t.Errorf("%s: expected 873 daily windows, got %d", loc, n)
+		}
+	}
+}
+
+// TODO: fuzz it (generating a random policy, and a seed for generating 1000
+// random time intervals), checking the guarantees for Prune (and ensuring it
+// works adding the times one at a time).
+
+func ExamplePrune() {
+	var times []time.Time
+	for i := 0; i < 5000*24*2; i++ {
+		times = append(times, time.Date(2000, 1, 1, 0, 30*i, int(prand(30*60, int64(i), 0xABCDEF0123456789)), 0, time.UTC))
+	}
+
+	var policy Policy
+	policy.MustSet(Yearly, 5, -1)
+	policy.MustSet(Yearly, 2, 10)
+	policy.MustSet(Yearly, 1, 3)
+	policy.MustSet(Monthly, 6, 4)
+	policy.MustSet(Monthly, 2, 6)
+	policy.MustSet(Daily, 1, 7)
+	policy.MustSet(Secondly, int(time.Hour/time.Second), 6)
+	policy.MustSet(Last, 1, 3)
+	fmt.Println(policy)
+
+	keep, need := Prune(times, policy, time.UTC)
+	for at, reason := range keep {
+		at := times[at]
+		if len(reason) != 0 {
+			var b strings.Builder
+			for i, r := range reason {
+				if i != 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(r.String())
+			}
+			fmt.Println(at.Format(time.ANSIC), "|", b.String())
+		}
+	}
+	fmt.Println(need)
+
+	// Output:
+	// last (3), 1h time (6), 1 day (7), 2 month (6), 6 month (4), 1 year (3), 2 year (10), 5 year (inf)
 	// Fri Dec 31 23:55:29 1999 | 2 year, 5 year
 	// Sat Jan  1 00:36:00 2000 | 2 year, 5 year
 	// Tue Jan  1 00:45:28 2002 | 2 year
@@ -531,6 +2053,68 @@ func ExamplePrune() {
 	// last (0), 1h time (0), 1 day (0), 2 month (0), 6 month (0), 1 year (0), 2 year (2), 5 year (inf)
 }
 
+func ExamplePruneFunc() {
+	type snapshot struct {
+		Name    string
+		Created time.Time
+	}
+	var snapshots []snapshot
+	for i := 0; i < 10; i++ {
+		t := time.Date(2024, 6, 1, 12*i, 0, 0, 0, time.UTC)
+		snapshots = append(snapshots, snapshot{"tank@" + t.Format("2006-01-02T15"), t})
+	}
+
+	policy, err := ParsePolicy("2@last", "3@daily")
+	if err != nil {
+		panic(err)
+	}
+
+	kept, pruned := PruneFunc(snapshots, func(s snapshot) time.Time {
+		return s.Created
+	}, policy, time.UTC)
+	for _, s := range kept {
+		fmt.Println("keep", s.Name)
+	}
+	for _, s := range pruned {
+		fmt.Println("prune", s.Name)
+	}
+
+	// Output:
+	// keep tank@2024-06-03T00
+	// keep tank@2024-06-04T00
+	// keep tank@2024-06-05T00
+	// keep tank@2024-06-05T12
+	// prune tank@2024-06-01T00
+	// prune tank@2024-06-01T12
+	// prune tank@2024-06-02T00
+	// prune tank@2024-06-02T12
+	// prune tank@2024-06-03T12
+	// prune tank@2024-06-04T12
+}
+
+func pruneOutput(times []time.Time, keep [][]Period, need Policy) []byte {
+	var b bytes.Buffer
+	for at, reason := range keep {
+		at := times[at]
+		if len(reason) != 0 {
+			b.WriteString(at.Format(time.ANSIC))
+			b.WriteString(" | ")
+			for i, r := range reason {
+				if i != 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(r.String())
+			}
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString(need.String())
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// prand is a simple deterministic pseudo-random generator. Use it with a
+// fixed-size integer type so the results are the same on all architectures.
 func prand[T ~uint | int | uint8 | int8 | uint16 | int16 | uint32 | int32 |
 	uint64 | int64](max, i T, seed uint64) T {
 	notEven := ((seed & 0xAAAAAAAAAAAAAAAA) >> 1) | ((seed & 0x5555555555555555) << 1) | 1
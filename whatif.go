@@ -0,0 +1,251 @@
+package snappr
+
+import (
+	"slices"
+	"time"
+)
+
+// WhatIf is the effect of adding a snapshot, as returned by Result.WhatIfAdd.
+type WhatIf struct {
+	// Reasons contains the periods which would require the new snapshot. If
+	// it is empty, the new snapshot would be pruned immediately.
+	Reasons []Period
+
+	// Displaced contains the indexes of the input snapshots which are
+	// currently kept, but would be pruned if the new snapshot was added.
+	Displaced []int
+
+	// Need contains the remaining number of snapshots required to fulfill the
+	// original policy after adding the snapshot.
+	Need Need
+}
+
+// WhatIfAdd determines the effect of adding a snapshot at t without pruning
+// all snapshots again (e.g., to decide whether to take a snapshot now). The
+// snapshots, policy, loc, and opt must be the same as for PruneResult.
+//
+// The new snapshot is ordered after existing snapshots with identical
+// timestamps (or before them if ReverseTies is set), and is only pinned if it
+// is within one of the Protected ranges.
+func (r Result) WhatIfAdd(t time.Time, snapshots []time.Time, policy Policy, loc *time.Location, opt *PruneOptions) WhatIf {
+	if opt == nil {
+		opt = new(PruneOptions)
+	}
+	t = t.Truncate(-1)
+
+	w := WhatIf{Need: Need(r.Need.Policy())}
+	if w.Need.count == nil {
+		w.Need.count = map[Period]int{}
+	}
+
+	// position of the new snapshot in the sorted snapshots
+	pos, _ := slices.BinarySearchFunc(r.sorted, t, func(i int, t time.Time) int {
+		if x := snapshots[i].Compare(t); x != 0 {
+			return x
+		}
+		if opt.ReverseTies {
+			return 1
+		}
+		return -1
+	})
+	isPinned := func(i int) bool {
+		if i == len(snapshots) {
+			for _, tr := range opt.Protected {
+				if tr.Contains(t) {
+					return true
+				}
+			}
+			return false
+		}
+		if opt.Pinned != nil && opt.Pinned(i) {
+			return true
+		}
+		for _, tr := range opt.Protected {
+			if tr.Contains(snapshots[i]) {
+				return true
+			}
+		}
+		return false
+	}
+	isPreferred := func(i int) bool {
+		return i != len(snapshots) && opt.Preferred != nil && opt.Preferred(i)
+	}
+	timeOf := func(i int) time.Time {
+		if i == len(snapshots) {
+			return t
+		}
+		return snapshots[i]
+	}
+
+	lost := map[int][]Period{} // by snapshot index
+	policy.Each(func(period Period, count int) {
+		var kept []int // sorted indexes of snapshots currently kept for the period
+		for k, i := range r.sorted {
+			if slices.Contains(r.Reasons[i], period) {
+				kept = append(kept, k)
+			}
+		}
+		unkeep := func(k int) {
+			lost[r.sorted[k]] = append(lost[r.sorted[k]], period)
+		}
+
+		if period.Unit == Last || period.Unit == Within {
+			// these depend on the position or age of every snapshot, so
+			// recompute the whole period
+			sorted := slices.Insert(slices.Clone(r.sorted), pos, len(snapshots))
+			now := opt.Now
+			if now.IsZero() {
+				if opt.Clock != nil {
+					now = opt.Clock.Now()
+				} else {
+					now = timeOf(sorted[len(sorted)-1])
+				}
+			}
+			newKept := map[int]bool{}
+			remaining := count
+			for k := len(sorted) - 1; k >= 0 && remaining != 0; k-- {
+				var match bool
+				if period.Unit == Last {
+					match = (len(sorted)-1-k)%period.Interval == 0
+				} else {
+					match = timeOf(sorted[k]).After(now.Add(-time.Duration(period.Interval) * time.Second))
+				}
+				if match {
+					if remaining > 0 {
+						remaining--
+					}
+					newKept[sorted[k]] = true
+				}
+			}
+			if newKept[len(snapshots)] {
+				w.Reasons = append(w.Reasons, period)
+			}
+			for _, k := range kept {
+				if !newKept[r.sorted[k]] {
+					unkeep(k)
+				}
+			}
+			w.Need.count[period] = remaining
+			return
+		}
+
+		bucket := func(t time.Time) int64 {
+			current := period.bucket(t, loc, opt)
+			if opt.SelectMode == ClosestSnapshot {
+				a := period.bucketStart(current, loc, opt).Add(period.Slack)
+				b := period.bucketStart(period.nextBucket(current, opt), loc, opt).Add(period.Slack)
+				if b.Sub(t) < t.Sub(a).Abs() {
+					current = period.nextBucket(current, opt)
+				}
+			}
+			return current
+		}
+		b := bucket(t)
+
+		// the snapshots in the same interval (buckets are monotonic)
+		lo, _ := slices.BinarySearchFunc(r.sorted, b, func(i int, b int64) int {
+			if bucket(snapshots[i]) < b {
+				return -1
+			}
+			return 1
+		})
+		hi, _ := slices.BinarySearchFunc(r.sorted, b, func(i int, b int64) int {
+			if bucket(snapshots[i]) <= b {
+				return -1
+			}
+			return 1
+		})
+
+		if lo == hi {
+			// new interval
+			if count < 0 || len(kept) < count {
+				w.Reasons = append(w.Reasons, period)
+				if count > 0 {
+					w.Need.count[period]--
+				}
+			} else if oldest := kept[0]; b > bucket(snapshots[r.sorted[oldest]]) {
+				w.Reasons = append(w.Reasons, period)
+				unkeep(oldest)
+			}
+			return
+		}
+
+		// existing interval, so the new snapshot can only replace its match
+		members := slices.Insert(slices.Clone(r.sorted[lo:hi]), pos-lo, len(snapshots))
+		start := period.bucketStart(b, loc, opt).Add(period.Slack)
+		var (
+			match     int // snapshot index
+			pin, pref bool
+		)
+		for k, i := range members {
+			if k == 0 {
+				match, pin, pref = i, isPinned(i), isPreferred(i)
+				continue
+			}
+			var replace bool
+			switch {
+			case pin != isPinned(i):
+				replace = !pin
+			case pref != isPreferred(i):
+				replace = !pref
+			case opt.SelectMode == LastSnapshot:
+				replace = true
+			case opt.SelectMode == ClosestSnapshot:
+				replace = timeOf(i).Sub(start).Abs() < timeOf(match).Sub(start).Abs()
+			}
+			if replace {
+				match, pin, pref = i, isPinned(i), isPreferred(i)
+			}
+		}
+		if match == len(snapshots) {
+			for _, k := range kept {
+				if k >= lo && k < hi {
+					w.Reasons = append(w.Reasons, period)
+					unkeep(k)
+				}
+			}
+		}
+	})
+	if isPinned(len(snapshots)) {
+		w.Reasons = append(w.Reasons, Period{Unit: Pinned, Interval: 1})
+	}
+	for _, i := range r.sorted {
+		if len(lost[i]) != 0 && len(lost[i]) == len(r.Reasons[i]) {
+			w.Displaced = append(w.Displaced, i)
+		}
+	}
+	return w
+}
+
+// PolicyComparison is the difference between the snapshots kept by two
+// policies, as returned by ComparePolicies.
+type PolicyComparison struct {
+	Old, New Result
+
+	// NewlyPruned contains the indexes of the input snapshots which are kept
+	// by the old policy but not the new one, in input order.
+	NewlyPruned []int
+
+	// NewlyKept contains the indexes of the input snapshots which are kept by
+	// the new policy but not the old one, in input order.
+	NewlyKept []int
+}
+
+// ComparePolicies prunes the snapshots with both policies to determine which
+// ones would be newly pruned or kept when changing from the old policy to the
+// new one (e.g., to avoid surprises when tightening retention).
+func ComparePolicies(snapshots []time.Time, old, new Policy, loc *time.Location, opt *PruneOptions) PolicyComparison {
+	c := PolicyComparison{
+		Old: PruneResult(snapshots, old, loc, opt),
+		New: PruneResult(snapshots, new, loc, opt),
+	}
+	for i := range snapshots {
+		switch a, b := len(c.Old.Reasons[i]) != 0, len(c.New.Reasons[i]) != 0; {
+		case a && !b:
+			c.NewlyPruned = append(c.NewlyPruned, i)
+		case !a && b:
+			c.NewlyKept = append(c.NewlyKept, i)
+		}
+	}
+	return c
+}
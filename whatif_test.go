@@ -0,0 +1,86 @@
+package snappr
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultWhatIfAdd(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, rules := range []string{
+		"3@last 7@daily 4@weekly 12@monthly",
+		"5@last:2 24@secondly:1h daily",
+		"within:36h 3@daily",
+		"10@daily:2+1 2@monthly",
+	} {
+		policy, err := ParsePolicy(strings.Fields(rules)...)
+		if err != nil {
+			panic(err)
+		}
+		for _, mode := range []SelectMode{FirstSnapshot, LastSnapshot, ClosestSnapshot} {
+			for n := 0; n < 50; n++ {
+				var (
+					base      = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+					snapshots = make([]time.Time, rng.Intn(60))
+				)
+				for i := range snapshots {
+					snapshots[i] = base.Add(time.Duration(rng.Int63n(int64(60 * 24 * time.Hour))))
+				}
+				add := base.Add(time.Duration(rng.Int63n(int64(70 * 24 * time.Hour))))
+				opt := &PruneOptions{
+					SelectMode: mode,
+					Protected:  []TimeRange{{base.Add(10 * 24 * time.Hour), base.Add(11 * 24 * time.Hour)}},
+				}
+
+				act := PruneResult(snapshots, policy, time.UTC, opt).WhatIfAdd(add, snapshots, policy, time.UTC, opt)
+				exp := PruneResult(append(slices.Clone(snapshots), add), policy, time.UTC, opt)
+
+				if !slices.Equal(act.Reasons, exp.Reasons[len(snapshots)]) {
+					t.Errorf("%s (mode %d): add %s: expected reasons %v, got %v", rules, mode, add, exp.Reasons[len(snapshots)], act.Reasons)
+				}
+				var displaced []int
+				for _, i := range PruneResult(snapshots, policy, time.UTC, opt).Kept() {
+					if len(exp.Reasons[i]) == 0 {
+						displaced = append(displaced, i)
+					}
+				}
+				slices.Sort(displaced)
+				slices.Sort(act.Displaced)
+				if !slices.Equal(act.Displaced, displaced) {
+					t.Errorf("%s (mode %d): add %s: expected displaced %v, got %v", rules, mode, add, displaced, act.Displaced)
+				}
+				if act.Need.String() != exp.Need.String() {
+					t.Errorf("%s (mode %d): add %s: expected need %s, got %s", rules, mode, add, exp.Need, act.Need)
+				}
+			}
+		}
+	}
+}
+
+func TestComparePolicies(t *testing.T) {
+	var snapshots []time.Time
+	for i := 9; i >= 0; i-- {
+		snapshots = append(snapshots, time.Date(2023, 1, 1+i, 12, 0, 0, 0, time.UTC))
+	}
+	old, err := ParsePolicy("5@daily")
+	if err != nil {
+		panic(err)
+	}
+	new, err := ParsePolicy("3@daily", "2@daily:3+1")
+	if err != nil {
+		panic(err)
+	}
+	c := ComparePolicies(snapshots, old, new, time.UTC, nil)
+	if exp := []int{3, 4}; !slices.Equal(c.NewlyPruned, exp) {
+		t.Errorf("expected newly pruned %v, got %v", exp, c.NewlyPruned)
+	}
+	if exp := []int{5}; !slices.Equal(c.NewlyKept, exp) {
+		t.Errorf("expected newly kept %v, got %v", exp, c.NewlyKept)
+	}
+	if !slices.Equal(c.Old.Kept(), []int{0, 1, 2, 3, 4}) {
+		t.Errorf("incorrect old result %v", c.Old.Kept())
+	}
+}